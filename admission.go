@@ -0,0 +1,317 @@
+package staking
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/0xPolygon/polygon-edge/types"
+	"github.com/0xPolygon/polygon-edge/validators"
+)
+
+// Slot definitions for the ranked admission extension of the SC storage.
+// When the validator set is full, a staker with more stake than the lowest staked
+// validator takes its place, and the evicted validator joins the candidates
+var (
+	candidatesSlot              = int64(21) // Slot 21
+	addressToIsCandidateSlot    = int64(22) // Slot 22
+	addressToCandidateIndexSlot = int64(23) // Slot 23
+)
+
+var (
+	ErrNotStaked                  = errors.New("address has no stake")
+	ErrMinValidators              = errors.New("validators can't be less than the minimum required validator num")
+	ErrInvalidCandidateStake      = errors.New("candidate stake must be positive")
+	ErrCandidateOutranksValidator = errors.New("candidate stake is above the lowest genesis validator stake")
+)
+
+// GenesisCandidate is an address staked at genesis without being an active validator
+type GenesisCandidate struct {
+	Address types.Address `json:"address"`
+	Stake   *big.Int      `json:"stake"`
+}
+
+// Admission is the outcome of a stake in the ranked validator set
+type Admission struct {
+	Admitted bool          // The staker is a validator after the stake
+	Evicted  types.Address // Validator moved into the candidates, the zero address if none
+}
+
+// RankedSet is the validator set of the ranked admission contract.
+//
+// Every staker that is not a validator is a candidate. A candidate reaching the threshold
+// joins the validators while there is room, and otherwise takes the place of the
+// lowest staked validator if it has strictly more stake.
+// When a validator unstakes, the highest staked candidate meeting the threshold
+// takes its place. Lowest stake ties evict the validator latest in the array,
+// and highest stake ties promote the candidate earliest in the array
+type RankedSet struct {
+	MinValidators uint64
+	MaxValidators uint64
+	Threshold     *big.Int
+
+	Validators []types.Address
+	Candidates []types.Address
+	Stakes     map[types.Address]*big.Int
+}
+
+// ReadRankedSet reads the ranked validator set from the staking contract storage
+func ReadRankedSet(r StorageReader) (*RankedSet, error) {
+	vals, err := readValidators(r)
+	if err != nil {
+		return nil, err
+	}
+
+	candidates, err := readAddressArray(r, candidatesSlot)
+	if err != nil {
+		return nil, err
+	}
+
+	minValidators, ok := readUint64(r, getSlotHash(minNumValidatorSlot))
+	if !ok {
+		return nil, ErrInvalidValidatorCount
+	}
+
+	maxValidators, ok := readUint64(r, getSlotHash(maxNumValidatorSlot))
+	if !ok {
+		return nil, ErrInvalidValidatorCount
+	}
+
+	// The threshold is only stored by the governed contract, which has an admin.
+	// The admin may set it to zero, so the value alone doesn't tell it apart
+	threshold := readBig(r, getSlotHash(validatorThresholdSlot))
+	if r.GetStorage(getSlotHash(adminSlot)) == types.ZeroHash {
+		val := DefaultStakedBalance
+
+		if threshold, err = types.ParseUint256orHex(&val); err != nil {
+			return nil, err
+		}
+	}
+
+	set := &RankedSet{
+		MinValidators: minValidators,
+		MaxValidators: maxValidators,
+		Threshold:     threshold,
+		Validators:    vals,
+		Candidates:    candidates,
+		Stakes:        make(map[types.Address]*big.Int, len(vals)+len(candidates)),
+	}
+
+	for _, addr := range append(append([]types.Address{}, vals...), candidates...) {
+		set.Stakes[addr] = readBig(r, types.BytesToHash(getAddressMapping(addr, addressToStakedAmountSlot)))
+	}
+
+	return set, nil
+}
+
+// StakeOf returns the stake of the address
+func (s *RankedSet) StakeOf(addr types.Address) *big.Int {
+	if stake, ok := s.Stakes[addr]; ok {
+		return new(big.Int).Set(stake)
+	}
+
+	return big.NewInt(0)
+}
+
+// IsValidator returns true if the address is an active validator
+func (s *RankedSet) IsValidator(addr types.Address) bool {
+	return indexOf(s.Validators, addr) >= 0
+}
+
+// IsCandidate returns true if the address is staked without being an active validator
+func (s *RankedSet) IsCandidate(addr types.Address) bool {
+	return indexOf(s.Candidates, addr) >= 0
+}
+
+// Stake adds the amount to the stake of the address and admits it as the contract does
+func (s *RankedSet) Stake(addr types.Address, amount *big.Int) Admission {
+	if s.Stakes == nil {
+		s.Stakes = make(map[types.Address]*big.Int)
+	}
+
+	stake := s.StakeOf(addr)
+	stake.Add(stake, amount)
+	s.Stakes[addr] = stake
+
+	if s.IsValidator(addr) {
+		return Admission{Admitted: true}
+	}
+
+	if !s.IsCandidate(addr) {
+		s.Candidates = append(s.Candidates, addr)
+	}
+
+	if stake.Cmp(s.Threshold) < 0 {
+		return Admission{}
+	}
+
+	if uint64(len(s.Validators)) < s.MaxValidators {
+		s.Candidates = removeAddress(s.Candidates, addr)
+		s.Validators = append(s.Validators, addr)
+
+		return Admission{Admitted: true}
+	}
+
+	lowest := s.lowestValidator()
+	if lowest < 0 || stake.Cmp(s.StakeOf(s.Validators[lowest])) <= 0 {
+		return Admission{}
+	}
+
+	// The staker takes the position of the evicted validator
+	evicted := s.Validators[lowest]
+
+	s.Candidates = removeAddress(s.Candidates, addr)
+	s.Candidates = append(s.Candidates, evicted)
+	s.Validators[lowest] = addr
+
+	return Admission{
+		Admitted: true,
+		Evicted:  evicted,
+	}
+}
+
+// lowestValidator returns the index of the validator to evict, or -1 if there are none
+func (s *RankedSet) lowestValidator() int {
+	lowest := -1
+
+	for idx, addr := range s.Validators {
+		if lowest < 0 || s.StakeOf(addr).Cmp(s.StakeOf(s.Validators[lowest])) <= 0 {
+			lowest = idx
+		}
+	}
+
+	return lowest
+}
+
+// highestCandidate returns the index of the candidate to promote, or -1 if no candidate
+// meets the threshold
+func (s *RankedSet) highestCandidate() int {
+	highest := -1
+
+	for idx, addr := range s.Candidates {
+		stake := s.StakeOf(addr)
+		if stake.Cmp(s.Threshold) < 0 {
+			continue
+		}
+
+		if highest < 0 || stake.Cmp(s.StakeOf(s.Candidates[highest])) > 0 {
+			highest = idx
+		}
+	}
+
+	return highest
+}
+
+// indexOf returns the index of the address in the array, or -1 if it is not in it
+func indexOf(addresses []types.Address, addr types.Address) int {
+	for idx, a := range addresses {
+		if a == addr {
+			return idx
+		}
+	}
+
+	return -1
+}
+
+// removeAddress removes the address from the array as the contract does,
+// moving the last element into its position
+func removeAddress(addresses []types.Address, addr types.Address) []types.Address {
+	index := indexOf(addresses, addr)
+	if index < 0 {
+		return addresses
+	}
+
+	last := len(addresses) - 1
+	addresses[index] = addresses[last]
+
+	return addresses[:last]
+}
+
+// setCandidatesStorage sets the genesis candidates into the genesis storage,
+// returning their total stake
+func setCandidatesStorage(w StorageWriter, h *slotHasher, candidates []GenesisCandidate) *big.Int {
+	total := big.NewInt(0)
+	trueValue := types.BytesToHash(big.NewInt(1).Bytes())
+
+	candidatesBase := h.dataIndex(getSlotHash(candidatesSlot))
+	isCandidateSlot := getSlotHash(addressToIsCandidateSlot)
+	candidateIndexSlot := getSlotHash(addressToCandidateIndexSlot)
+	stakeSlot := getSlotHash(addressToStakedAmountSlot)
+
+	for idx, candidate := range candidates {
+		total.Add(total, candidate.Stake)
+
+		w.SetStorage(
+			addOffset(candidatesBase, uint64(idx)),
+			types.BytesToHash(candidate.Address.Bytes()),
+		)
+
+		w.SetStorage(h.addressMapping(candidate.Address, isCandidateSlot), trueValue)
+
+		w.SetStorage(
+			h.addressMapping(candidate.Address, candidateIndexSlot),
+			addOffset(types.ZeroHash, uint64(idx)),
+		)
+
+		w.SetStorage(
+			h.addressMapping(candidate.Address, stakeSlot),
+			types.BytesToHash(candidate.Stake.Bytes()),
+		)
+	}
+
+	w.SetStorage(getSlotHash(candidatesSlot), addOffset(types.ZeroHash, uint64(len(candidates))))
+
+	return total
+}
+
+// validateGenesisCandidates checks that the genesis candidates are staked addresses
+// that are not genesis validators, and that they keep the ranking of the validator set.
+//
+// Candidates are placed in the pool without being activated, even if they reach the threshold
+// while the validator set has room, as the contract promotes them on the next unstake.
+// When the validator set is full, no candidate can have more stake than the lowest staked
+// validator, which the contract would have evicted in its favor
+func validateGenesisCandidates(
+	candidates []GenesisCandidate,
+	vals validators.Validators,
+	genesisVals map[types.Address]bool,
+	maxValidators uint64,
+	stakeOf func(types.Address) *big.Int,
+) error {
+	// The stake a candidate can't exceed, nil if the validator set has room
+	var lowestStake *big.Int
+
+	if vals != nil && uint64(vals.Len()) >= maxValidators {
+		for idx := 0; idx < vals.Len(); idx++ {
+			if stake := stakeOf(vals.At(uint64(idx)).Addr()); lowestStake == nil || stake.Cmp(lowestStake) < 0 {
+				lowestStake = stake
+			}
+		}
+	}
+
+	seen := make(map[types.Address]bool, len(candidates))
+
+	for _, candidate := range candidates {
+		addr := candidate.Address
+
+		if genesisVals[addr] {
+			return fmt.Errorf("candidate %s is a genesis validator", addr)
+		}
+
+		if seen[addr] {
+			return fmt.Errorf("duplicate candidate %s", addr)
+		}
+
+		seen[addr] = true
+
+		if candidate.Stake == nil || candidate.Stake.Sign() <= 0 {
+			return fmt.Errorf("invalid candidate %s: %w", addr, ErrInvalidCandidateStake)
+		}
+
+		if lowestStake != nil && candidate.Stake.Cmp(lowestStake) > 0 {
+			return fmt.Errorf("invalid candidate %s: %w", addr, ErrCandidateOutranksValidator)
+		}
+	}
+
+	return nil
+}
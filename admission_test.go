@@ -0,0 +1,105 @@
+package staking
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/0xPolygon/polygon-edge/types"
+)
+
+func TestGenesisCandidatesRanking(t *testing.T) {
+	t.Parallel()
+
+	vals := testBLSValidators(3)
+	lowest := vals.At(2).Addr()
+	candidate := types.StringToAddress("c")
+
+	stakes := map[types.Address]*big.Int{
+		vals.At(0).Addr(): big.NewInt(300),
+		vals.At(1).Addr(): big.NewInt(200),
+		lowest:            big.NewInt(100),
+	}
+
+	testTable := []struct {
+		name          string
+		maxValidators uint64
+		stake         int64
+		err           error
+	}{
+		{"full set with a candidate at the lowest stake", 3, 100, nil},
+		{"full set with a candidate above the lowest stake", 3, 101, ErrCandidateOutranksValidator},
+		{"candidate above the threshold while the set has room", 4, 1000, nil},
+	}
+
+	for _, test := range testTable {
+		test := test
+
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := PredeployStakingSC(vals, PredeployParams{
+				MinValidatorCount: 1,
+				MaxValidatorCount: test.maxValidators,
+				Admin:             types.StringToAddress("ad0"),
+				StakeThreshold:    big.NewInt(100),
+				Stakes:            stakes,
+				Bytecode:          testExtendedBytecode,
+				BytecodeHash:      testExtendedBytecodeHash,
+				Candidates:        []GenesisCandidate{{Address: candidate, Stake: big.NewInt(test.stake)}},
+			})
+
+			if !errors.Is(err, test.err) {
+				t.Fatalf("expected %v, got %v", test.err, err)
+			}
+		})
+	}
+}
+
+func TestReadRankedSetThreshold(t *testing.T) {
+	t.Parallel()
+
+	vals := testBLSValidators(1)
+	admin := types.StringToAddress("ad0")
+
+	testTable := []struct {
+		name      string
+		admin     types.Address
+		threshold *big.Int
+		expected  string
+	}{
+		{"fixed parameters", types.ZeroAddress, nil, DefaultStakedBalance},
+		{"governed threshold", admin, big.NewInt(1), "0x1"},
+		{"governed zero threshold", admin, big.NewInt(0), "0x0"},
+	}
+
+	for _, test := range testTable {
+		test := test
+
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			account, err := PredeployStakingSC(vals, PredeployParams{
+				MinValidatorCount: 1,
+				MaxValidatorCount: 1,
+				Admin:             test.admin,
+				StakeThreshold:    test.threshold,
+				Bytecode:          testExtendedBytecode,
+				BytecodeHash:      testExtendedBytecodeHash,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			set, err := ReadRankedSet(StorageMap(account.Storage))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			expected, _ := types.ParseUint256orHex(&test.expected)
+			if set.Threshold.Cmp(expected) != 0 {
+				t.Fatalf("threshold is %s, expected %s", set.Threshold, expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,186 @@
+package staking
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/coinbase/kryptology/pkg/signatures/bls/bls_sig"
+)
+
+var (
+	ErrEmptySignerBitmap = errors.New("signer bitmap is empty")
+	ErrNegativeBitmap    = errors.New("signer bitmap can't be negative")
+	ErrMissingStake      = errors.New("validator has no stake")
+	ErrSignerOutOfRange  = errors.New("signer bitmap refers to a validator out of range")
+	ErrInvalidQuorum     = errors.New("invalid quorum")
+	ErrQuorumNotReached  = errors.New("signers don't reach the quorum")
+	ErrInvalidSignature  = errors.New("invalid aggregated signature")
+)
+
+// QuorumMode selects how the weight of the signers is measured
+type QuorumMode uint8
+
+const (
+	// QuorumByCount gives every validator the same weight
+	QuorumByCount QuorumMode = iota
+	// QuorumByStake weights every validator by its stake
+	QuorumByStake
+)
+
+// Quorum is the fraction of the total weight the signers need to reach
+type Quorum struct {
+	Mode        QuorumMode
+	Numerator   uint64
+	Denominator uint64
+}
+
+// TwoThirdsQuorum is reached with at least 2/3 of the validators
+var TwoThirdsQuorum = Quorum{
+	Mode:        QuorumByCount,
+	Numerator:   2,
+	Denominator: 3,
+}
+
+// Validate checks that the quorum is a fraction in (0, 1]
+func (q Quorum) Validate() error {
+	if q.Denominator == 0 || q.Numerator == 0 || q.Numerator > q.Denominator {
+		return ErrInvalidQuorum
+	}
+
+	if q.Mode != QuorumByCount && q.Mode != QuorumByStake {
+		return ErrInvalidQuorum
+	}
+
+	return nil
+}
+
+// validateSignerBitmap checks that the bitmap marks at least one signer, all of them in the entries
+func validateSignerBitmap(entries []ValidatorEntry, bitmap *big.Int) error {
+	if bitmap == nil || bitmap.Sign() == 0 {
+		return ErrEmptySignerBitmap
+	}
+
+	// Bit uses the two's complement of negative values, which would mark every validator
+	if bitmap.Sign() < 0 {
+		return ErrNegativeBitmap
+	}
+
+	if bitmap.BitLen() > len(entries) {
+		return ErrSignerOutOfRange
+	}
+
+	return nil
+}
+
+// Weights returns the weight of the signers marked in the bitmap and the total weight of the set
+func (q Quorum) Weights(entries []ValidatorEntry, bitmap *big.Int) (*big.Int, *big.Int, error) {
+	if err := validateSignerBitmap(entries, bitmap); err != nil {
+		return nil, nil, err
+	}
+
+	signed, total := big.NewInt(0), big.NewInt(0)
+
+	for idx := range entries {
+		weight := big.NewInt(1)
+		if q.Mode == QuorumByStake {
+			if entries[idx].Stake == nil {
+				return nil, nil, fmt.Errorf("%w: %s", ErrMissingStake, entries[idx].Address)
+			}
+
+			weight = entries[idx].Stake
+		}
+
+		total.Add(total, weight)
+
+		if bitmap.Bit(idx) == 1 {
+			signed.Add(signed, weight)
+		}
+	}
+
+	return signed, total, nil
+}
+
+// Reached returns true if the signers marked in the bitmap reach the quorum:
+// signed / total >= numerator / denominator
+func (q Quorum) Reached(entries []ValidatorEntry, bitmap *big.Int) (bool, error) {
+	if err := q.Validate(); err != nil {
+		return false, err
+	}
+
+	signed, total, err := q.Weights(entries, bitmap)
+	if err != nil {
+		return false, err
+	}
+
+	signed.Mul(signed, new(big.Int).SetUint64(q.Denominator))
+	total.Mul(total, new(big.Int).SetUint64(q.Numerator))
+
+	return total.Sign() > 0 && signed.Cmp(total) >= 0, nil
+}
+
+// AggregatePublicKey returns the aggregate of the BLS public keys the validators
+// registered in the staking contract, for the signers marked in the bitmap.
+// Bit i of the bitmap marks the validator at index i of the validators array
+func AggregatePublicKey(entries []ValidatorEntry, bitmap *big.Int) (*bls_sig.MultiPublicKey, error) {
+	if err := validateSignerBitmap(entries, bitmap); err != nil {
+		return nil, err
+	}
+
+	pubkeys := make([]*bls_sig.PublicKey, 0, len(entries))
+
+	for idx := range entries {
+		if bitmap.Bit(idx) == 0 {
+			continue
+		}
+
+		pubkey := &bls_sig.PublicKey{}
+		if err := pubkey.UnmarshalBinary(entries[idx].BLSPublicKey); err != nil {
+			return nil, fmt.Errorf("invalid BLS public key of %s: %w", entries[idx].Address, err)
+		}
+
+		pubkeys = append(pubkeys, pubkey)
+	}
+
+	return bls_sig.NewSigPop().AggregatePublicKeys(pubkeys...)
+}
+
+// VerifyAggregatedSignature checks that the signers marked in the bitmap reach the quorum
+// and that the aggregated signature of the message is theirs
+func VerifyAggregatedSignature(
+	entries []ValidatorEntry,
+	bitmap *big.Int,
+	message []byte,
+	signature []byte,
+	quorum Quorum,
+) error {
+	reached, err := quorum.Reached(entries, bitmap)
+	if err != nil {
+		return err
+	}
+
+	if !reached {
+		return ErrQuorumNotReached
+	}
+
+	aggregatedPubKey, err := AggregatePublicKey(entries, bitmap)
+	if err != nil {
+		return err
+	}
+
+	aggregatedSignature := &bls_sig.MultiSignature{}
+	if err := aggregatedSignature.UnmarshalBinary(signature); err != nil {
+		return err
+	}
+
+	ok, err := bls_sig.NewSigPop().VerifyMultiSignature(aggregatedPubKey, message, aggregatedSignature)
+	if err != nil {
+		return err
+	}
+
+	if !ok {
+		return ErrInvalidSignature
+	}
+
+	return nil
+}
@@ -0,0 +1,231 @@
+package staking
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/coinbase/kryptology/pkg/signatures/bls/bls_sig"
+)
+
+// testBLSEntries returns n validator entries with BLS public keys, and their secret keys
+func testBLSEntries(t *testing.T, n int) ([]ValidatorEntry, []*bls_sig.SecretKey) {
+	t.Helper()
+
+	entries := testValidatorEntries(n)
+	secrets := make([]*bls_sig.SecretKey, n)
+
+	for idx := range entries {
+		seed := make([]byte, 32)
+		seed[0] = byte(idx) + 1
+
+		pubkey, secret, err := bls_sig.NewSigPop().KeygenWithSeed(seed)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if entries[idx].BLSPublicKey, err = pubkey.MarshalBinary(); err != nil {
+			t.Fatal(err)
+		}
+
+		secrets[idx] = secret
+	}
+
+	return entries, secrets
+}
+
+// testAggregatedSignature returns the aggregated signature of the message by the signers
+// marked in the bitmap
+func testAggregatedSignature(
+	t *testing.T,
+	secrets []*bls_sig.SecretKey,
+	bitmap *big.Int,
+	message []byte,
+) []byte {
+	t.Helper()
+
+	signatures := make([]*bls_sig.Signature, 0, len(secrets))
+
+	for idx, secret := range secrets {
+		if bitmap.Bit(idx) == 0 {
+			continue
+		}
+
+		signature, err := bls_sig.NewSigPop().Sign(secret, message)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		signatures = append(signatures, signature)
+	}
+
+	aggregated, err := bls_sig.NewSigPop().AggregateSignatures(signatures...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	raw, err := aggregated.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return raw
+}
+
+func TestQuorumValidate(t *testing.T) {
+	t.Parallel()
+
+	testTable := []struct {
+		name   string
+		quorum Quorum
+		valid  bool
+	}{
+		{"two thirds", TwoThirdsQuorum, true},
+		{"all the stake", Quorum{Mode: QuorumByStake, Numerator: 1, Denominator: 1}, true},
+		{"zero denominator", Quorum{Mode: QuorumByCount, Numerator: 1}, false},
+		{"zero numerator", Quorum{Mode: QuorumByCount, Denominator: 3}, false},
+		{"above one", Quorum{Mode: QuorumByCount, Numerator: 4, Denominator: 3}, false},
+		{"unknown mode", Quorum{Mode: QuorumByStake + 1, Numerator: 2, Denominator: 3}, false},
+	}
+
+	for _, test := range testTable {
+		test := test
+
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			err := test.quorum.Validate()
+			if test.valid && err != nil {
+				t.Fatal(err)
+			}
+
+			if !test.valid && !errors.Is(err, ErrInvalidQuorum) {
+				t.Fatalf("expected %v, got %v", ErrInvalidQuorum, err)
+			}
+		})
+	}
+}
+
+func TestQuorumReached(t *testing.T) {
+	t.Parallel()
+
+	// Stakes 1, 2, 3 and 4
+	entries := testValidatorEntries(4)
+	twoThirdsOfStake := Quorum{Mode: QuorumByStake, Numerator: 2, Denominator: 3}
+
+	testTable := []struct {
+		name    string
+		quorum  Quorum
+		bitmap  *big.Int
+		reached bool
+		err     error
+	}{
+		{"three of four validators", TwoThirdsQuorum, big.NewInt(0b0111), true, nil},
+		{"two of four validators", TwoThirdsQuorum, big.NewInt(0b1100), false, nil},
+		{"seven of ten stake", twoThirdsOfStake, big.NewInt(0b1100), true, nil},
+		{"six of ten stake", twoThirdsOfStake, big.NewInt(0b0111), false, nil},
+		{"all the stake", Quorum{Mode: QuorumByStake, Numerator: 1, Denominator: 1}, big.NewInt(0b1111), true, nil},
+		{"invalid quorum", Quorum{Mode: QuorumByCount, Numerator: 1}, big.NewInt(0b1111), false, ErrInvalidQuorum},
+		{"nil bitmap", TwoThirdsQuorum, nil, false, ErrEmptySignerBitmap},
+		{"empty bitmap", TwoThirdsQuorum, big.NewInt(0), false, ErrEmptySignerBitmap},
+		{"negative bitmap", TwoThirdsQuorum, big.NewInt(-1), false, ErrNegativeBitmap},
+		{"signer out of range", TwoThirdsQuorum, big.NewInt(0b10111), false, ErrSignerOutOfRange},
+	}
+
+	for _, test := range testTable {
+		test := test
+
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			reached, err := test.quorum.Reached(entries, test.bitmap)
+			if !errors.Is(err, test.err) {
+				t.Fatalf("expected %v, got %v", test.err, err)
+			}
+
+			if reached != test.reached {
+				t.Fatalf("expected reached %v, got %v", test.reached, reached)
+			}
+		})
+	}
+}
+
+func TestQuorumReachedMissingStake(t *testing.T) {
+	t.Parallel()
+
+	entries := testValidatorEntries(3)
+	entries[2].Stake = nil
+
+	// Counting validators doesn't need the stakes
+	if reached, err := TwoThirdsQuorum.Reached(entries, big.NewInt(0b011)); err != nil || !reached {
+		t.Fatalf("expected the quorum by count to be reached, got %v, %v", reached, err)
+	}
+
+	byStake := Quorum{Mode: QuorumByStake, Numerator: 2, Denominator: 3}
+	if _, err := byStake.Reached(entries, big.NewInt(0b011)); !errors.Is(err, ErrMissingStake) {
+		t.Fatalf("expected %v, got %v", ErrMissingStake, err)
+	}
+}
+
+func TestVerifyAggregatedSignature(t *testing.T) {
+	t.Parallel()
+
+	entries, secrets := testBLSEntries(t, 4)
+	message := []byte("block hash")
+	bitmap := big.NewInt(0b1011)
+	signature := testAggregatedSignature(t, secrets, bitmap, message)
+
+	invalidKey := append([]ValidatorEntry{}, entries...)
+	invalidKey[1].BLSPublicKey = []byte{1, 2, 3}
+
+	testTable := []struct {
+		name      string
+		entries   []ValidatorEntry
+		bitmap    *big.Int
+		message   []byte
+		signature []byte
+		err       error
+	}{
+		{"signed by the signers", entries, bitmap, message, signature, nil},
+		{"other message", entries, bitmap, []byte("other block hash"), signature, ErrInvalidSignature},
+		{"other signers", entries, big.NewInt(0b0111), message, signature, ErrInvalidSignature},
+		{
+			"quorum not reached",
+			entries,
+			big.NewInt(0b0011),
+			message,
+			testAggregatedSignature(t, secrets, big.NewInt(0b0011), message),
+			ErrQuorumNotReached,
+		},
+		{"empty bitmap", entries, big.NewInt(0), message, signature, ErrEmptySignerBitmap},
+	}
+
+	for _, test := range testTable {
+		test := test
+
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			err := VerifyAggregatedSignature(test.entries, test.bitmap, test.message, test.signature, TwoThirdsQuorum)
+			if !errors.Is(err, test.err) {
+				t.Fatalf("expected %v, got %v", test.err, err)
+			}
+		})
+	}
+
+	t.Run("malformed signature", func(t *testing.T) {
+		t.Parallel()
+
+		if err := VerifyAggregatedSignature(entries, bitmap, message, signature[1:], TwoThirdsQuorum); err == nil {
+			t.Fatal("expected an error")
+		}
+	})
+
+	t.Run("invalid public key", func(t *testing.T) {
+		t.Parallel()
+
+		if err := VerifyAggregatedSignature(invalidKey, bitmap, message, signature, TwoThirdsQuorum); err == nil {
+			t.Fatal("expected an error")
+		}
+	})
+}
@@ -0,0 +1,253 @@
+package staking
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/0xPolygon/polygon-edge/helper/hex"
+	"github.com/0xPolygon/polygon-edge/helper/keccak"
+)
+
+const (
+	// StakingSCCodeHash is the keccak256 hash of the decoded StakingSCBytecode
+	StakingSCCodeHash = "0x358f93564ef04e6b398c7a3b78aa339f686699aac50318f17f52a8d348a601e1"
+
+	// StakingSCCompilerVersion is the solc version StakingSCBytecode was compiled with
+	StakingSCCompilerVersion = "0.8.7"
+
+	// StakingSCMetadataHash is the IPFS multihash of the solc metadata of StakingSCBytecode,
+	// which commits to the contract sources and the compiler settings
+	StakingSCMetadataHash = "0x1220c49057f5cecf8004854d139d54ce63f88afdb16f93d1102e6d26a7b081d22f5f"
+)
+
+var (
+	ErrCodeHashMismatch     = errors.New("code hash doesn't match the pinned hash")
+	ErrMissingMetadata      = errors.New("code has no compiler metadata")
+	ErrInvalidMetadata      = errors.New("invalid compiler metadata")
+	ErrCompilerMismatch     = errors.New("compiler version doesn't match")
+	ErrMetadataHashMismatch = errors.New("metadata hash doesn't match")
+)
+
+// CompilerMetadata is the metadata solc appends to the deployed code,
+// a CBOR encoded map followed by its length as a 2 byte big endian integer
+type CompilerMetadata struct {
+	IPFS []byte // IPFS multihash of the metadata JSON
+	Solc string // Version of the compiler, such as "0.8.7"
+}
+
+// StakingSCCode returns the decoded StakingSCBytecode, after checking it against StakingSCCodeHash
+func StakingSCCode() ([]byte, error) {
+	code, err := hex.DecodeHex(StakingSCBytecode)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := VerifyCodeHash(code, StakingSCCodeHash); err != nil {
+		return nil, err
+	}
+
+	return code, nil
+}
+
+// VerifyCodeHash checks that the keccak256 hash of the code matches the hex encoded hash
+func VerifyCodeHash(code []byte, codeHash string) error {
+	expected, err := hex.DecodeHex(codeHash)
+	if err != nil {
+		return err
+	}
+
+	if actual := keccak.Keccak256(nil, code); !bytes.Equal(actual, expected) {
+		return fmt.Errorf("%w: expected %s, got %s", ErrCodeHashMismatch, codeHash, hex.EncodeToHex(actual))
+	}
+
+	return nil
+}
+
+// VerifyBuild checks that the code was produced by the given compiler version
+// from the sources committed to by the hex encoded metadata hash
+func VerifyBuild(code []byte, compilerVersion string, metadataHash string) error {
+	metadata, err := ParseCompilerMetadata(code)
+	if err != nil {
+		return err
+	}
+
+	if metadata.Solc != compilerVersion {
+		return fmt.Errorf("%w: expected %s, got %s", ErrCompilerMismatch, compilerVersion, metadata.Solc)
+	}
+
+	expected, err := hex.DecodeHex(metadataHash)
+	if err != nil {
+		return err
+	}
+
+	if !bytes.Equal(metadata.IPFS, expected) {
+		return fmt.Errorf(
+			"%w: expected %s, got %s",
+			ErrMetadataHashMismatch,
+			metadataHash,
+			hex.EncodeToHex(metadata.IPFS),
+		)
+	}
+
+	return nil
+}
+
+// VerifyStakingSCBuild checks StakingSCBytecode against the recorded compiler version and metadata hash
+func VerifyStakingSCBuild() error {
+	code, err := StakingSCCode()
+	if err != nil {
+		return err
+	}
+
+	return VerifyBuild(code, StakingSCCompilerVersion, StakingSCMetadataHash)
+}
+
+// ParseCompilerMetadata parses the CBOR metadata trailer of the deployed code
+//
+// More information:
+// https://docs.soliditylang.org/en/latest/metadata.html#encoding-of-the-metadata-hash-in-the-bytecode
+func ParseCompilerMetadata(code []byte) (*CompilerMetadata, error) {
+	if len(code) < 2 {
+		return nil, ErrMissingMetadata
+	}
+
+	length := int(binary.BigEndian.Uint16(code[len(code)-2:]))
+	if length == 0 || length > len(code)-2 {
+		return nil, ErrMissingMetadata
+	}
+
+	decoder := &cborDecoder{data: code[len(code)-2-length : len(code)-2]}
+
+	major, entries, err := decoder.readHead()
+	if err != nil {
+		return nil, err
+	}
+
+	if major != cborMap {
+		return nil, ErrInvalidMetadata
+	}
+
+	metadata := &CompilerMetadata{}
+
+	for idx := uint64(0); idx < entries; idx++ {
+		keyMajor, key, err := decoder.readItem()
+		if err != nil {
+			return nil, err
+		}
+
+		if keyMajor != cborText {
+			return nil, ErrInvalidMetadata
+		}
+
+		valueMajor, value, err := decoder.readItem()
+		if err != nil {
+			return nil, err
+		}
+
+		switch string(key) {
+		case "ipfs":
+			if valueMajor != cborBytes {
+				return nil, ErrInvalidMetadata
+			}
+
+			metadata.IPFS = value
+		case "solc":
+			switch {
+			// Release builds encode the version as 3 bytes
+			case valueMajor == cborBytes && len(value) == 3:
+				metadata.Solc = fmt.Sprintf("%d.%d.%d", value[0], value[1], value[2])
+			// Prerelease builds encode the full version string
+			case valueMajor == cborText:
+				metadata.Solc = string(value)
+			default:
+				return nil, ErrInvalidMetadata
+			}
+		}
+	}
+
+	if len(decoder.data) != 0 {
+		return nil, ErrInvalidMetadata
+	}
+
+	return metadata, nil
+}
+
+// CBOR major types used by the solc metadata
+const (
+	cborBytes  = 2
+	cborText   = 3
+	cborMap    = 5
+	cborSimple = 7
+)
+
+// cborDecoder is a minimal CBOR decoder for the items solc puts in the metadata map
+type cborDecoder struct {
+	data []byte
+}
+
+// readHead reads the major type and argument of the next item
+func (d *cborDecoder) readHead() (byte, uint64, error) {
+	if len(d.data) == 0 {
+		return 0, 0, ErrInvalidMetadata
+	}
+
+	major, info := d.data[0]>>5, d.data[0]&0x1f
+	d.data = d.data[1:]
+
+	var size int
+
+	switch {
+	case info < 24:
+		return major, uint64(info), nil
+	case info == 24:
+		size = 1
+	case info == 25:
+		size = 2
+	case info == 26:
+		size = 4
+	case info == 27:
+		size = 8
+	default:
+		return 0, 0, ErrInvalidMetadata
+	}
+
+	if len(d.data) < size {
+		return 0, 0, ErrInvalidMetadata
+	}
+
+	argument := uint64(0)
+	for _, b := range d.data[:size] {
+		argument = argument<<8 | uint64(b)
+	}
+
+	d.data = d.data[size:]
+
+	return major, argument, nil
+}
+
+// readItem reads the next byte string, text string or simple value
+func (d *cborDecoder) readItem() (byte, []byte, error) {
+	major, argument, err := d.readHead()
+	if err != nil {
+		return 0, nil, err
+	}
+
+	switch major {
+	case cborBytes, cborText:
+		if argument > uint64(len(d.data)) {
+			return 0, nil, ErrInvalidMetadata
+		}
+
+		value := d.data[:argument]
+		d.data = d.data[argument:]
+
+		return major, value, nil
+	case cborSimple:
+		// Flags such as "experimental": true
+		return major, nil, nil
+	default:
+		return 0, nil, ErrInvalidMetadata
+	}
+}
@@ -0,0 +1,120 @@
+package staking
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"testing"
+
+	"github.com/0xPolygon/polygon-edge/helper/hex"
+)
+
+// withMetadata returns the code followed by the CBOR metadata and its length
+func withMetadata(code []byte, cbor string) []byte {
+	metadata := hex.MustDecodeHex(cbor)
+
+	length := make([]byte, 2)
+	binary.BigEndian.PutUint16(length, uint16(len(metadata)))
+
+	return append(append(append([]byte{}, code...), metadata...), length...)
+}
+
+func TestParseCompilerMetadataSolcTrailer(t *testing.T) {
+	t.Parallel()
+
+	code, err := StakingSCCode()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	metadata, err := ParseCompilerMetadata(code)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if metadata.Solc != StakingSCCompilerVersion {
+		t.Fatalf("compiler version is %s, expected %s", metadata.Solc, StakingSCCompilerVersion)
+	}
+
+	if !bytes.Equal(metadata.IPFS, hex.MustDecodeHex(StakingSCMetadataHash)) {
+		t.Fatalf("metadata hash is %x, expected %s", metadata.IPFS, StakingSCMetadataHash)
+	}
+
+	if err := VerifyStakingSCBuild(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestParseCompilerMetadataPrerelease(t *testing.T) {
+	t.Parallel()
+
+	// {"solc": "0.8.8-ci", "experimental": true}
+	code := withMetadata([]byte{0x60, 0x80}, "0xa264736f6c6368302e382e382d63696c6578706572696d656e74616cf5")
+
+	metadata, err := ParseCompilerMetadata(code)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if metadata.Solc != "0.8.8-ci" || metadata.IPFS != nil {
+		t.Fatalf("parsed %+v", metadata)
+	}
+}
+
+func TestParseCompilerMetadataInvalid(t *testing.T) {
+	t.Parallel()
+
+	testTable := []struct {
+		name string
+		code []byte
+		err  error
+	}{
+		{"no trailer", []byte{0x60}, ErrMissingMetadata},
+		{"zero length", []byte{0x60, 0x80, 0x00, 0x00}, ErrMissingMetadata},
+		{"length past the code", []byte{0x60, 0x80, 0x00, 0x10}, ErrMissingMetadata},
+		{"not a map", withMetadata(nil, "0x4100"), ErrInvalidMetadata},
+		{"map with more entries than encoded", withMetadata(nil, "0xa2646970667341aa"), ErrInvalidMetadata},
+		{"byte string longer than the trailer", withMetadata(nil, "0xa164697066735820aabb"), ErrInvalidMetadata},
+		{"truncated length argument", withMetadata(nil, "0xa1646970667359"), ErrInvalidMetadata},
+		{"reserved additional information", withMetadata(nil, "0xa164697066735c"), ErrInvalidMetadata},
+		{"unsigned integer value", withMetadata(nil, "0xa1646970667301"), ErrInvalidMetadata},
+		{"integer key", withMetadata(nil, "0xa10141aa"), ErrInvalidMetadata},
+		{"text ipfs hash", withMetadata(nil, "0xa1646970667361aa"), ErrInvalidMetadata},
+		{"two byte solc version", withMetadata(nil, "0xa164736f6c63420008"), ErrInvalidMetadata},
+		{"bytes after the map", withMetadata(nil, "0xa000"), ErrInvalidMetadata},
+	}
+
+	for _, test := range testTable {
+		test := test
+
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			if _, err := ParseCompilerMetadata(test.code); !errors.Is(err, test.err) {
+				t.Fatalf("expected %v, got %v", test.err, err)
+			}
+		})
+	}
+}
+
+func TestVerifyBuildMismatch(t *testing.T) {
+	t.Parallel()
+
+	code, err := StakingSCCode()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := VerifyBuild(code, "0.8.8", StakingSCMetadataHash); !errors.Is(err, ErrCompilerMismatch) {
+		t.Fatalf("expected %v, got %v", ErrCompilerMismatch, err)
+	}
+
+	otherHash := "0x1220" + StakingSCMetadataHash[6:len(StakingSCMetadataHash)-2] + "00"
+	if err := VerifyBuild(code, StakingSCCompilerVersion, otherHash); !errors.Is(err, ErrMetadataHashMismatch) {
+		t.Fatalf("expected %v, got %v", ErrMetadataHashMismatch, err)
+	}
+
+	if err := VerifyCodeHash(append(code, 0x00), StakingSCCodeHash); !errors.Is(err, ErrCodeHashMismatch) {
+		t.Fatalf("expected %v, got %v", ErrCodeHashMismatch, err)
+	}
+}
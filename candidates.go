@@ -0,0 +1,168 @@
+package staking
+
+import (
+	"math/big"
+
+	"github.com/0xPolygon/polygon-edge/types"
+	"github.com/umbracle/ethgo"
+	"github.com/umbracle/ethgo/abi"
+)
+
+var (
+	candidatesMethod = abi.MustNewMethod("function candidates() view returns (address[] candidates)")
+)
+
+// Staker is an address with stake in the ranked admission contract
+type Staker struct {
+	Address     types.Address
+	Stake       *big.Int
+	Active      bool     // The staker is in the validator set
+	StakeNeeded *big.Int // Additional stake needed to become a validator, nil if it can't become one
+}
+
+// StakeNeeded returns the additional stake the address needs to become a validator,
+// zero for validators and nil if the validator set can't take any validator.
+//
+// A staker needs to reach the threshold while the validator set has room,
+// and to exceed the lowest validator stake once it is full
+func (s *RankedSet) StakeNeeded(addr types.Address) *big.Int {
+	if s.IsValidator(addr) {
+		return big.NewInt(0)
+	}
+
+	required := new(big.Int).Set(s.Threshold)
+
+	if uint64(len(s.Validators)) >= s.MaxValidators {
+		lowest := s.lowestValidator()
+		if lowest < 0 {
+			return nil
+		}
+
+		outrank := s.StakeOf(s.Validators[lowest])
+		outrank.Add(outrank, big.NewInt(1))
+
+		if outrank.Cmp(required) > 0 {
+			required = outrank
+		}
+	}
+
+	needed := required.Sub(required, s.StakeOf(addr))
+	if needed.Sign() < 0 {
+		return big.NewInt(0)
+	}
+
+	return needed
+}
+
+// Stakers returns every staked address, the validators first and then the candidates
+// in their array order
+func (s *RankedSet) Stakers() []Staker {
+	stakers := make([]Staker, 0, len(s.Validators)+len(s.Candidates))
+
+	for _, addr := range s.Validators {
+		stakers = append(stakers, Staker{
+			Address:     addr,
+			Stake:       s.StakeOf(addr),
+			Active:      true,
+			StakeNeeded: big.NewInt(0),
+		})
+	}
+
+	for _, addr := range s.Candidates {
+		stakers = append(stakers, Staker{
+			Address:     addr,
+			Stake:       s.StakeOf(addr),
+			StakeNeeded: s.StakeNeeded(addr),
+		})
+	}
+
+	return stakers
+}
+
+// ReadStakers reads every staked address from the staking contract storage
+func ReadStakers(r StorageReader) ([]Staker, error) {
+	set, err := ReadRankedSet(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return set.Stakers(), nil
+}
+
+// Stakers gets every staked address from contract
+func (c *Client) Stakers(from types.Address) ([]Staker, error) {
+	set, err := c.RankedSet(from)
+	if err != nil {
+		return nil, err
+	}
+
+	return set.Stakers(), nil
+}
+
+// RankedSet gets the ranked validator set from contract
+func (c *Client) RankedSet(from types.Address) (*RankedSet, error) {
+	set := &RankedSet{}
+
+	for _, query := range []struct {
+		method *abi.Method
+		name   string
+		value  *[]types.Address
+	}{
+		{validatorsMethod, "validators", &set.Validators},
+		{candidatesMethod, "candidates", &set.Candidates},
+	} {
+		outputs, err := c.callView(from, query.method)
+		if err != nil {
+			return nil, err
+		}
+
+		addresses, ok := outputs[query.name].([]ethgo.Address)
+		if !ok {
+			return nil, ErrFailedTypeAssertion
+		}
+
+		*query.value = make([]types.Address, len(addresses))
+		for idx, addr := range addresses {
+			(*query.value)[idx] = types.Address(addr)
+		}
+	}
+
+	limits := make([]*big.Int, 3)
+
+	for idx, method := range []*abi.Method{
+		minimumNumValidatorsMethod,
+		maximumNumValidatorsMethod,
+		validatorThresholdMethod,
+	} {
+		outputs, err := c.callView(from, method)
+		if err != nil {
+			return nil, err
+		}
+
+		if limits[idx], err = decodeBig(outputs, "value"); err != nil {
+			return nil, err
+		}
+	}
+
+	if !limits[0].IsUint64() || !limits[1].IsUint64() {
+		return nil, ErrInvalidValidatorCount
+	}
+
+	set.MinValidators = limits[0].Uint64()
+	set.MaxValidators = limits[1].Uint64()
+	set.Threshold = limits[2]
+	set.Stakes = make(map[types.Address]*big.Int, len(set.Validators)+len(set.Candidates))
+
+	for _, addr := range append(append([]types.Address{}, set.Validators...), set.Candidates...) {
+		outputs, err := c.callView(from, accountStakeMethod, ethgo.Address(addr))
+		if err != nil {
+			return nil, err
+		}
+
+		if set.Stakes[addr], err = decodeBig(outputs, "stake"); err != nil {
+			return nil, err
+		}
+	}
+
+	return set, nil
+}
@@ -0,0 +1,384 @@
+package staking
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+
+	"github.com/0xPolygon/polygon-edge/chain"
+	"github.com/0xPolygon/polygon-edge/helper/common"
+	"github.com/0xPolygon/polygon-edge/helper/hex"
+	"github.com/0xPolygon/polygon-edge/types"
+	"github.com/0xPolygon/polygon-edge/validators"
+)
+
+// Versions of the staking contract a chain can predeploy
+const (
+	// StakingVersionBase is the contract of StakingSCBytecode
+	StakingVersionBase = "base"
+	// StakingVersionExtended is an extended contract, whose bytecode is part of the config
+	StakingVersionExtended = "extended"
+)
+
+// stakingConfigPath is the location of the staking config in the chain file
+const stakingConfigPath = "params.staking"
+
+var (
+	ErrMissingField     = errors.New("is required")
+	ErrExtendedOnly     = errors.New("is only supported by the extended version")
+	ErrUnknownVersion   = errors.New("unknown version")
+	ErrAddressInUse     = errors.New("genesis alloc already has an account at the address")
+	ErrOutOfRangeNumber = errors.New("out of range")
+	ErrInvalidBytecode  = errors.New("invalid hex bytecode")
+	ErrAdminRequired    = errors.New("is only stored by the governed contract, which requires an admin")
+)
+
+// ConfigError is an invalid field of the staking config
+type ConfigError struct {
+	Field string // Path of the field in the chain file
+	Err   error
+}
+
+// Error returns the path of the field and what is wrong with it
+func (e *ConfigError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Field, e.Err)
+}
+
+// Unwrap returns the underlying error
+func (e *ConfigError) Unwrap() error {
+	return e.Err
+}
+
+// configError returns the error about the field of the staking config
+func configError(field string, err error) error {
+	return &ConfigError{
+		Field: stakingConfigPath + "." + field,
+		Err:   err,
+	}
+}
+
+// stakingConfigJSON is the staking section of the chain file, as written in it
+type stakingConfigJSON struct {
+	Address           *types.Address           `json:"address"`
+	Version           string                   `json:"version"`
+	Bytecode          string                   `json:"bytecode"`
+	BytecodeHash      string                   `json:"bytecodeHash"`
+	MinValidatorCount *uint64                  `json:"minValidatorCount"`
+	MaxValidatorCount *uint64                  `json:"maxValidatorCount"`
+	Stakes            map[types.Address]string `json:"stakes"`
+	Admin             types.Address            `json:"admin"`
+	StakeThreshold    string                   `json:"stakeThreshold"`
+	EpochSize         uint64                   `json:"epochSize"`
+	Candidates        []struct {
+		Address types.Address `json:"address"`
+		Stake   string        `json:"stake"`
+	} `json:"candidates"`
+}
+
+// StakingConfig is the staking section of the chain file, from which the staking contract
+// is predeployed into the genesis allocation. For example:
+//
+//	"params": {
+//		"staking": {
+//			"address": "0x0000000000000000000000000000000000001001",
+//			"version": "extended",
+//			"bytecode": "0x6080...",
+//			"bytecodeHash": "0x...",
+//			"minValidatorCount": 4,
+//			"maxValidatorCount": 100,
+//			"stakes": {"0x...": "0x1bc16d674ec80000"},
+//			"admin": "0x...",
+//			"stakeThreshold": "0x8ac7230489e80000",
+//			"epochSize": 100,
+//			"candidates": [{"address": "0x...", "stake": "0x4563918244f40000"}]
+//		}
+//	}
+//
+// The address defaults to AddrStakingContract and the validator counts to MinValidatorCount
+// and MaxValidatorCount. Genesis validators missing from the stakes are staked with
+// DefaultStakedBalance. The stake threshold is only stored by the governed contract,
+// so it requires an admin
+type StakingConfig struct {
+	Address types.Address
+	Version string
+	Params  PredeployParams
+}
+
+// ParseStakingConfig parses and validates the staking section of the chain file,
+// returning nil if the chain has none
+func ParseStakingConfig(chainJSON []byte) (*StakingConfig, error) {
+	var chainFile struct {
+		Params struct {
+			Staking json.RawMessage `json:"staking"`
+		} `json:"params"`
+	}
+
+	if err := json.Unmarshal(chainJSON, &chainFile); err != nil {
+		return nil, fmt.Errorf("invalid chain file: %w", err)
+	}
+
+	raw := chainFile.Params.Staking
+	if len(raw) == 0 || bytes.Equal(raw, []byte("null")) {
+		return nil, nil
+	}
+
+	// Unknown fields are rejected, so that misspelled settings don't go unnoticed
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.DisallowUnknownFields()
+
+	var config stakingConfigJSON
+	if err := decoder.Decode(&config); err != nil {
+		return nil, fmt.Errorf("%s: %w", stakingConfigPath, err)
+	}
+
+	return config.toStakingConfig()
+}
+
+// ImportStakingConfig parses and validates the staking section of the chain file at the path,
+// returning nil if the chain has none
+func ImportStakingConfig(filename string) (*StakingConfig, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseStakingConfig(data)
+}
+
+// toStakingConfig validates the staking section and converts it into the predeploy params
+func (c *stakingConfigJSON) toStakingConfig() (*StakingConfig, error) {
+	config := &StakingConfig{
+		Address: AddrStakingContract,
+		Version: c.Version,
+		Params: PredeployParams{
+			MinValidatorCount: MinValidatorCount,
+			MaxValidatorCount: MaxValidatorCount,
+			EpochSize:         c.EpochSize,
+		},
+	}
+
+	if c.Address != nil {
+		if *c.Address == types.ZeroAddress {
+			return nil, configError("address", ErrInvalidInstanceAddress)
+		}
+
+		config.Address = *c.Address
+	}
+
+	switch c.Version {
+	case "":
+		return nil, configError("version", ErrMissingField)
+	case StakingVersionBase:
+		// The base contract has no extension slots
+		for _, field := range []struct {
+			name string
+			set  bool
+		}{
+			{"bytecode", c.Bytecode != ""},
+			{"bytecodeHash", c.BytecodeHash != ""},
+			{"admin", c.Admin != types.ZeroAddress},
+			{"stakeThreshold", c.StakeThreshold != ""},
+			{"epochSize", c.EpochSize != 0},
+			{"candidates", len(c.Candidates) > 0},
+		} {
+			if field.set {
+				return nil, configError(field.name, ErrExtendedOnly)
+			}
+		}
+	case StakingVersionExtended:
+		if c.Bytecode == "" {
+			return nil, configError("bytecode", ErrMissingField)
+		}
+
+		code, err := hex.DecodeHex(c.Bytecode)
+		if err != nil || len(code) == 0 {
+			return nil, configError("bytecode", ErrInvalidBytecode)
+		}
+
+		// The extended contract isn't part of the package, so its code is pinned in the config
+		if c.BytecodeHash == "" {
+			return nil, configError("bytecodeHash", ErrMissingField)
+		}
+
+		if err := VerifyCodeHash(code, c.BytecodeHash); err != nil {
+			return nil, configError("bytecodeHash", err)
+		}
+
+		config.Params.Bytecode = code
+		config.Params.BytecodeHash = c.BytecodeHash
+	default:
+		return nil, configError("version", fmt.Errorf(
+			"%w %q, expected %q or %q",
+			ErrUnknownVersion,
+			c.Version,
+			StakingVersionBase,
+			StakingVersionExtended,
+		))
+	}
+
+	if c.MinValidatorCount != nil {
+		config.Params.MinValidatorCount = *c.MinValidatorCount
+	}
+
+	if c.MaxValidatorCount != nil {
+		config.Params.MaxValidatorCount = *c.MaxValidatorCount
+	}
+
+	if config.Params.MinValidatorCount < 1 {
+		return nil, configError("minValidatorCount", fmt.Errorf("%w, must be at least 1", ErrOutOfRangeNumber))
+	}
+
+	if config.Params.MaxValidatorCount > common.MaxSafeJSInt {
+		return nil, configError("maxValidatorCount", fmt.Errorf(
+			"%w, must be at most %d",
+			ErrOutOfRangeNumber,
+			uint64(common.MaxSafeJSInt),
+		))
+	}
+
+	if config.Params.MaxValidatorCount < config.Params.MinValidatorCount {
+		return nil, configError("maxValidatorCount", fmt.Errorf(
+			"%w, must be at least minValidatorCount %d",
+			ErrOutOfRangeNumber,
+			config.Params.MinValidatorCount,
+		))
+	}
+
+	for addr, value := range c.Stakes {
+		field := fmt.Sprintf("stakes.%s", addr)
+
+		if value == "" {
+			return nil, configError(field, ErrMissingField)
+		}
+
+		stake, err := parseConfigAmount(value)
+		if err != nil {
+			return nil, configError(field, err)
+		}
+
+		if config.Params.Stakes == nil {
+			config.Params.Stakes = make(map[types.Address]*big.Int, len(c.Stakes))
+		}
+
+		config.Params.Stakes[addr] = stake
+	}
+
+	config.Params.Admin = c.Admin
+
+	if c.StakeThreshold != "" {
+		if c.Admin == types.ZeroAddress {
+			return nil, configError("stakeThreshold", ErrAdminRequired)
+		}
+
+		threshold, err := parseConfigAmount(c.StakeThreshold)
+		if err != nil {
+			return nil, configError("stakeThreshold", err)
+		}
+
+		config.Params.StakeThreshold = threshold
+	}
+
+	for idx, candidate := range c.Candidates {
+		field := fmt.Sprintf("candidates[%d]", idx)
+
+		if candidate.Address == types.ZeroAddress {
+			return nil, configError(field+".address", ErrMissingField)
+		}
+
+		if candidate.Stake == "" {
+			return nil, configError(field+".stake", ErrMissingField)
+		}
+
+		stake, err := parseConfigAmount(candidate.Stake)
+		if err != nil {
+			return nil, configError(field+".stake", err)
+		}
+
+		config.Params.Candidates = append(config.Params.Candidates, GenesisCandidate{
+			Address: candidate.Address,
+			Stake:   stake,
+		})
+	}
+
+	return config, nil
+}
+
+// parseConfigAmount parses a decimal or hex uint256 amount of the staking config
+func parseConfigAmount(value string) (*big.Int, error) {
+	amount, err := types.ParseUint256orHex(&value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid amount %q, expected a decimal or hex uint256", value)
+	}
+
+	return amount, nil
+}
+
+// Expand predeploys the staking contract with the validators into the genesis allocation
+func (c *StakingConfig) Expand(genesis *chain.Genesis, vals validators.Validators) error {
+	valsLen := uint64(0)
+	if vals != nil {
+		valsLen = uint64(vals.Len())
+	}
+
+	if valsLen < c.Params.MinValidatorCount || valsLen > c.Params.MaxValidatorCount {
+		return fmt.Errorf(
+			"%s: %d genesis validators, out of the range [%d, %d]",
+			stakingConfigPath,
+			valsLen,
+			c.Params.MinValidatorCount,
+			c.Params.MaxValidatorCount,
+		)
+	}
+
+	if _, ok := genesis.Alloc[c.Address]; ok {
+		return configError("address", fmt.Errorf("%w %s", ErrAddressInUse, c.Address))
+	}
+
+	account, err := PredeployStakingSC(vals, c.Params)
+	if err != nil {
+		return fmt.Errorf("%s: %w", stakingConfigPath, err)
+	}
+
+	if genesis.Alloc == nil {
+		genesis.Alloc = make(map[types.Address]*chain.GenesisAccount)
+	}
+
+	genesis.Alloc[c.Address] = account
+
+	return nil
+}
+
+// ImportChain imports the chain file at the path and predeploys its staking section,
+// if it has one, with the validators into the genesis allocation
+func ImportChain(filename string, vals validators.Validators) (*chain.Chain, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	chainConfig := &chain.Chain{
+		Genesis: &chain.Genesis{},
+	}
+
+	if err := json.Unmarshal(data, chainConfig); err != nil {
+		return nil, fmt.Errorf("invalid chain file: %w", err)
+	}
+
+	config, err := ParseStakingConfig(data)
+	if err != nil {
+		return nil, err
+	}
+
+	if config == nil {
+		return chainConfig, nil
+	}
+
+	if err := config.Expand(chainConfig.Genesis, vals); err != nil {
+		return nil, err
+	}
+
+	return chainConfig, nil
+}
@@ -0,0 +1,95 @@
+package staking
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/0xPolygon/polygon-edge/types"
+)
+
+func TestParseStakingConfigStakesAndAdmin(t *testing.T) {
+	t.Parallel()
+
+	config, err := ParseStakingConfig([]byte(`{"params": {"staking": {
+		"version": "extended",
+		"bytecode": "0x6080",
+		"bytecodeHash": "0x1a578b7a4b0b5755db6d121b4118d4bc68fe170dca840c59bc922f14175a76b0",
+		"stakes": {"0x0000000000000000000000000000000000000a01": "20000000000000000000"},
+		"admin": "0x0000000000000000000000000000000000000ad0",
+		"stakeThreshold": "0x8ac7230489e80000"
+	}}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stake := config.Params.Stakes[types.StringToAddress("a01")]
+	if stake == nil || stake.Cmp(new(big.Int).Mul(big.NewInt(20), big.NewInt(1e18))) != 0 {
+		t.Fatalf("stake is %v, expected 20 ETH", stake)
+	}
+
+	if config.Params.Admin != types.StringToAddress("ad0") {
+		t.Fatalf("admin is %s", config.Params.Admin)
+	}
+
+	if config.Params.StakeThreshold == nil {
+		t.Fatal("stake threshold is not set")
+	}
+}
+
+func TestParseStakingConfigInvalidFields(t *testing.T) {
+	t.Parallel()
+
+	testTable := []struct {
+		name    string
+		staking string
+		field   string
+		err     error
+	}{
+		{
+			name:    "stake threshold without admin",
+			staking: `{"version": "extended", "bytecode": "0x6080", "bytecodeHash": "` + testExtendedBytecodeHash + `", "stakeThreshold": "1"}`,
+			field:   "params.staking.stakeThreshold",
+			err:     ErrAdminRequired,
+		},
+		{
+			name:    "extended contract without a code hash",
+			staking: `{"version": "extended", "bytecode": "0x6080"}`,
+			field:   "params.staking.bytecodeHash",
+			err:     ErrMissingField,
+		},
+		{
+			name:    "extended contract with another code hash",
+			staking: `{"version": "extended", "bytecode": "0x6081", "bytecodeHash": "` + testExtendedBytecodeHash + `"}`,
+			field:   "params.staking.bytecodeHash",
+			err:     ErrCodeHashMismatch,
+		},
+		{
+			name:    "admin of the base contract",
+			staking: `{"version": "base", "admin": "0x0000000000000000000000000000000000000ad0"}`,
+			field:   "params.staking.admin",
+			err:     ErrExtendedOnly,
+		},
+		{
+			name:    "empty stake",
+			staking: `{"version": "base", "stakes": {"0x0000000000000000000000000000000000000a01": ""}}`,
+			field:   "params.staking.stakes.0x0000000000000000000000000000000000000a01",
+			err:     ErrMissingField,
+		},
+	}
+
+	for _, test := range testTable {
+		test := test
+
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := ParseStakingConfig([]byte(`{"params": {"staking": ` + test.staking + `}}`))
+
+			var configErr *ConfigError
+			if !errors.As(err, &configErr) || configErr.Field != test.field || !errors.Is(err, test.err) {
+				t.Fatalf("expected %s: %v, got %v", test.field, test.err, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,280 @@
+package staking
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/0xPolygon/polygon-edge/helper/common"
+	"github.com/0xPolygon/polygon-edge/helper/keccak"
+	"github.com/0xPolygon/polygon-edge/types"
+	"github.com/umbracle/ethgo"
+	"github.com/umbracle/ethgo/abi"
+)
+
+// Domain separation prefixes of the validator set Merkle tree,
+// so that a node can never be presented as a leaf or as the root
+const (
+	leafPrefix = byte(0x00)
+	nodePrefix = byte(0x01)
+	rootPrefix = byte(0x02)
+)
+
+var (
+	ErrIndexOutOfRange      = errors.New("index out of range")
+	ErrMismatchedQueryCount = errors.New("validators and BLS public keys count mismatch")
+
+	validatorsMethod             = abi.MustNewMethod("function validators() view returns (address[] validators)")
+	validatorBLSPublicKeysMethod = abi.MustNewMethod("function validatorBLSPublicKeys() view returns (bytes[] keys)")
+	accountStakeMethod           = abi.MustNewMethod("function accountStake(address addr) view returns (uint256 stake)")
+)
+
+// ValidatorEntry is a validator of the staking contract, as committed to by the validator set root
+type ValidatorEntry struct {
+	Address      types.Address
+	BLSPublicKey []byte
+	Stake        *big.Int
+}
+
+// leafHash returns the hash of the entry in the validator set Merkle tree:
+// keccak256(0x00 || address || keccak256(blsPublicKey) || uint256 stake)
+func (e *ValidatorEntry) leafHash() (types.Hash, error) {
+	if e.Stake == nil {
+		return types.ZeroHash, fmt.Errorf("%w: %s", ErrMissingStake, e.Address)
+	}
+
+	data := make([]byte, 0, 1+types.AddressLength+2*types.HashLength)
+
+	data = append(data, leafPrefix)
+	data = append(data, e.Address.Bytes()...)
+	data = append(data, keccak.Keccak256(nil, e.BLSPublicKey)...)
+	data = append(data, common.PadLeftOrTrim(e.Stake.Bytes(), 32)...)
+
+	return types.BytesToHash(keccak.Keccak256(nil, data)), nil
+}
+
+// nodeHash returns the hash of an inner node: keccak256(0x01 || left || right)
+func nodeHash(left, right types.Hash) types.Hash {
+	data := make([]byte, 0, 1+2*types.HashLength)
+
+	data = append(data, nodePrefix)
+	data = append(data, left.Bytes()...)
+	data = append(data, right.Bytes()...)
+
+	return types.BytesToHash(keccak.Keccak256(nil, data))
+}
+
+// rootHash returns the root committing to the set size as well as the tree,
+// so that the shape of the tree, and with it the index of a leaf, is authenticated:
+// keccak256(0x02 || uint256 count || treeRoot)
+func rootHash(count uint64, treeRoot types.Hash) types.Hash {
+	data := make([]byte, 0, 1+2*types.HashLength)
+
+	data = append(data, rootPrefix)
+	data = append(data, common.PadLeftOrTrim(new(big.Int).SetUint64(count).Bytes(), 32)...)
+	data = append(data, treeRoot.Bytes()...)
+
+	return types.BytesToHash(keccak.Keccak256(nil, data))
+}
+
+// ValidatorSetCommitment is a Merkle tree over the validator set, in the validators array order.
+//
+// An unpaired node at the end of a level is carried up to the next level unchanged,
+// and the tree root of the empty set is the zero hash
+type ValidatorSetCommitment struct {
+	levels [][]types.Hash // Leaves first, root last
+}
+
+// InclusionProof proves that an entry is at the given index of the committed validator set
+type InclusionProof struct {
+	Index    uint64       // Index of the entry in the validators array
+	Count    uint64       // Number of validators in the set
+	Siblings []types.Hash // Sibling hashes from the leaf level up
+}
+
+// NewValidatorSetCommitment builds the commitment to the validator set entries
+func NewValidatorSetCommitment(entries []ValidatorEntry) (*ValidatorSetCommitment, error) {
+	leaves := make([]types.Hash, len(entries))
+
+	for idx := range entries {
+		leaf, err := entries[idx].leafHash()
+		if err != nil {
+			return nil, err
+		}
+
+		leaves[idx] = leaf
+	}
+
+	levels := [][]types.Hash{leaves}
+
+	for level := leaves; len(level) > 1; {
+		next := make([]types.Hash, 0, (len(level)+1)/2)
+
+		for idx := 0; idx < len(level); idx += 2 {
+			if idx+1 == len(level) {
+				next = append(next, level[idx])
+			} else {
+				next = append(next, nodeHash(level[idx], level[idx+1]))
+			}
+		}
+
+		levels = append(levels, next)
+		level = next
+	}
+
+	return &ValidatorSetCommitment{
+		levels: levels,
+	}, nil
+}
+
+// Root returns the root of the validator set, committing to the Merkle tree and the set size
+func (c *ValidatorSetCommitment) Root() types.Hash {
+	treeRoot := types.ZeroHash
+	if top := c.levels[len(c.levels)-1]; len(top) != 0 {
+		treeRoot = top[0]
+	}
+
+	return rootHash(uint64(len(c.levels[0])), treeRoot)
+}
+
+// Proof returns the inclusion proof of the validator at the given index
+func (c *ValidatorSetCommitment) Proof(index uint64) (*InclusionProof, error) {
+	count := uint64(len(c.levels[0]))
+	if index >= count {
+		return nil, ErrIndexOutOfRange
+	}
+
+	proof := &InclusionProof{
+		Index:    index,
+		Count:    count,
+		Siblings: make([]types.Hash, 0, len(c.levels)-1),
+	}
+
+	for _, level := range c.levels[:len(c.levels)-1] {
+		sibling := index ^ 1
+
+		// Carried up nodes have no sibling
+		if sibling < uint64(len(level)) {
+			proof.Siblings = append(proof.Siblings, level[sibling])
+		}
+
+		index /= 2
+	}
+
+	return proof, nil
+}
+
+// VerifyInclusion checks that the proof includes the entry in the validator set with the given root.
+//
+// The root commits to the set size, which fixes the shape of the tree,
+// so the proof only verifies for the index and count it was made for
+func VerifyInclusion(root types.Hash, entry *ValidatorEntry, proof *InclusionProof) bool {
+	if proof.Index >= proof.Count {
+		return false
+	}
+
+	hash, err := entry.leafHash()
+	if err != nil {
+		return false
+	}
+
+	index, count := proof.Index, proof.Count
+	siblings := proof.Siblings
+
+	for count > 1 {
+		switch {
+		case index%2 == 1:
+			if len(siblings) == 0 {
+				return false
+			}
+
+			hash = nodeHash(siblings[0], hash)
+			siblings = siblings[1:]
+		case index+1 < count:
+			if len(siblings) == 0 {
+				return false
+			}
+
+			hash = nodeHash(hash, siblings[0])
+			siblings = siblings[1:]
+		}
+
+		index /= 2
+		count = (count + 1) / 2
+	}
+
+	return len(siblings) == 0 && rootHash(proof.Count, hash) == root
+}
+
+// ReadValidatorEntries reads the validator set entries from the staking contract storage
+func ReadValidatorEntries(r StorageReader) ([]ValidatorEntry, error) {
+	vals, err := readValidators(r)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]ValidatorEntry, len(vals))
+
+	for idx, addr := range vals {
+		blsPublicKey, err := getBytesFromStorage(r, getAddressMapping(addr, addressToBLSPublicKeySlot))
+		if err != nil {
+			return nil, err
+		}
+
+		entries[idx] = ValidatorEntry{
+			Address:      addr,
+			BLSPublicKey: blsPublicKey,
+			Stake:        readBig(r, types.BytesToHash(getAddressMapping(addr, addressToStakedAmountSlot))),
+		}
+	}
+
+	return entries, nil
+}
+
+// ValidatorEntries gets the validator set entries from contract
+func (c *Client) ValidatorEntries(from types.Address) ([]ValidatorEntry, error) {
+	outputs, err := c.callView(from, validatorsMethod)
+	if err != nil {
+		return nil, err
+	}
+
+	addresses, ok := outputs["validators"].([]ethgo.Address)
+	if !ok {
+		return nil, ErrFailedTypeAssertion
+	}
+
+	if outputs, err = c.callView(from, validatorBLSPublicKeysMethod); err != nil {
+		return nil, err
+	}
+
+	keys, ok := outputs["keys"].([][]byte)
+	if !ok {
+		return nil, ErrFailedTypeAssertion
+	}
+
+	if len(keys) != len(addresses) {
+		return nil, ErrMismatchedQueryCount
+	}
+
+	entries := make([]ValidatorEntry, len(addresses))
+
+	for idx, addr := range addresses {
+		outputs, err := c.callView(from, accountStakeMethod, addr)
+		if err != nil {
+			return nil, err
+		}
+
+		stake, err := decodeBig(outputs, "stake")
+		if err != nil {
+			return nil, err
+		}
+
+		entries[idx] = ValidatorEntry{
+			Address:      types.Address(addr),
+			BLSPublicKey: keys[idx],
+			Stake:        stake,
+		}
+	}
+
+	return entries, nil
+}
@@ -0,0 +1,115 @@
+package staking
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"testing"
+
+	"github.com/0xPolygon/polygon-edge/types"
+)
+
+// testValidatorEntries returns n validator entries of the test BLS validators
+func testValidatorEntries(n int) []ValidatorEntry {
+	vals := testBLSValidators(n)
+	entries := make([]ValidatorEntry, n)
+
+	for idx := range entries {
+		entries[idx] = ValidatorEntry{
+			Address:      vals.At(uint64(idx)).Addr(),
+			BLSPublicKey: []byte{byte(idx)},
+			Stake:        big.NewInt(int64(idx) + 1),
+		}
+	}
+
+	return entries
+}
+
+func TestValidatorSetCommitmentProofs(t *testing.T) {
+	t.Parallel()
+
+	for n := 1; n <= 9; n++ {
+		n := n
+
+		t.Run(fmt.Sprintf("%d validators", n), func(t *testing.T) {
+			t.Parallel()
+
+			entries := testValidatorEntries(n)
+
+			commitment, err := NewValidatorSetCommitment(entries)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			root := commitment.Root()
+
+			for idx := range entries {
+				proof, err := commitment.Proof(uint64(idx))
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if !VerifyInclusion(root, &entries[idx], proof) {
+					t.Fatalf("proof of entry %d doesn't verify", idx)
+				}
+
+				// The same siblings must not verify at any other position or set size
+				for index := uint64(0); index < 10; index++ {
+					for count := index + 1; count <= 10; count++ {
+						if index == proof.Index && count == proof.Count {
+							continue
+						}
+
+						forged := &InclusionProof{Index: index, Count: count, Siblings: proof.Siblings}
+						if VerifyInclusion(root, &entries[idx], forged) {
+							t.Fatalf("proof of entry %d of %d verifies as entry %d of %d", idx, n, index, count)
+						}
+					}
+				}
+			}
+
+			if _, err := commitment.Proof(uint64(n)); !errors.Is(err, ErrIndexOutOfRange) {
+				t.Fatalf("expected %v, got %v", ErrIndexOutOfRange, err)
+			}
+		})
+	}
+}
+
+func TestValidatorSetCommitmentForgedIndex(t *testing.T) {
+	t.Parallel()
+
+	entries := testValidatorEntries(3)
+
+	commitment, err := NewValidatorSetCommitment(entries)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	proof, err := commitment.Proof(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The last entry of an odd set is carried up, so its path is the one of
+	// the second entry of a set of two
+	forged := &InclusionProof{Index: 1, Count: 2, Siblings: proof.Siblings}
+	if VerifyInclusion(commitment.Root(), &entries[2], forged) {
+		t.Fatal("proof verifies with a forged index and count")
+	}
+}
+
+func TestValidatorSetCommitmentMissingStake(t *testing.T) {
+	t.Parallel()
+
+	entries := testValidatorEntries(2)
+	entries[1].Stake = nil
+
+	if _, err := NewValidatorSetCommitment(entries); !errors.Is(err, ErrMissingStake) {
+		t.Fatalf("expected %v, got %v", ErrMissingStake, err)
+	}
+
+	proof := &InclusionProof{Index: 1, Count: 2}
+	if VerifyInclusion(types.ZeroHash, &entries[1], proof) {
+		t.Fatal("entry without a stake verifies")
+	}
+}
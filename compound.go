@@ -0,0 +1,258 @@
+package staking
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+
+	"github.com/0xPolygon/polygon-edge/types"
+	"github.com/umbracle/ethgo"
+	"github.com/umbracle/ethgo/abi"
+)
+
+// Slot definitions for the auto-compounding extension of the SC storage.
+// Rewards of compounding stakers are added to their stake,
+// and the others accrue them until they claim
+var (
+	addressToAutoCompoundSlot  = int64(24) // Slot 24
+	addressToPendingRewardSlot = int64(25) // Slot 25
+)
+
+var (
+	ErrNoRewards          = errors.New("no rewards to claim")
+	ErrTotalStakeMismatch = errors.New("total staked amount doesn't match the sum of the stakes")
+	ErrBalanceMismatch    = errors.New("contract balance doesn't match the stakes and pending rewards")
+
+	setAutoCompoundMethod = abi.MustNewMethod("function setAutoCompound(bool enabled)")
+	autoCompoundMethod    = abi.MustNewMethod("function autoCompound(address account) view returns (bool enabled)")
+	claimRewardsMethod    = abi.MustNewMethod("function claimRewards()")
+)
+
+// RewardEngine credits rewards to the stakers of the auto-compounding contract.
+//
+// The rewards are paid into the contract. A compounding staker has its reward added
+// to its stake and to the total staked amount, and any other staker has it added
+// to its pending rewards, which leave the contract when claimed.
+// The contract balance is therefore always the total stake plus the pending rewards
+type RewardEngine struct {
+	Stakes       map[types.Address]*big.Int // addressToStakedAmount
+	TotalStaked  *big.Int                   // stakedAmount
+	AutoCompound map[types.Address]bool     // addressToAutoCompound
+	Pending      map[types.Address]*big.Int // addressToPendingReward
+	Balance      *big.Int                   // Balance of the staked asset held by the contract
+}
+
+// NewRewardEngine creates a reward engine over the stakes, with the contract holding exactly
+// the total stake and no pending rewards
+func NewRewardEngine(stakes map[types.Address]*big.Int) *RewardEngine {
+	e := &RewardEngine{
+		Stakes:       make(map[types.Address]*big.Int, len(stakes)),
+		TotalStaked:  big.NewInt(0),
+		AutoCompound: make(map[types.Address]bool),
+		Pending:      make(map[types.Address]*big.Int),
+	}
+
+	for addr, stake := range stakes {
+		e.Stakes[addr] = new(big.Int).Set(stake)
+		e.TotalStaked.Add(e.TotalStaked, stake)
+	}
+
+	e.Balance = new(big.Int).Set(e.TotalStaked)
+
+	return e
+}
+
+// SetAutoCompound sets the compounding flag of the staker
+func (e *RewardEngine) SetAutoCompound(staker types.Address, enabled bool) {
+	if enabled {
+		e.AutoCompound[staker] = true
+	} else {
+		delete(e.AutoCompound, staker)
+	}
+}
+
+// Distribute pays the rewards into the contract and credits them to the stakers
+func (e *RewardEngine) Distribute(rewards map[types.Address]*big.Int) error {
+	for _, reward := range rewards {
+		if reward.Sign() < 0 {
+			return ErrNegativeAmount
+		}
+	}
+
+	for staker, reward := range rewards {
+		if reward.Sign() == 0 {
+			continue
+		}
+
+		e.Balance.Add(e.Balance, reward)
+
+		if e.AutoCompound[staker] {
+			e.Stakes[staker] = new(big.Int).Add(e.stakeOf(staker), reward)
+			e.TotalStaked.Add(e.TotalStaked, reward)
+
+			continue
+		}
+
+		e.Pending[staker] = new(big.Int).Add(e.pendingOf(staker), reward)
+	}
+
+	return nil
+}
+
+// Claim pays out the pending rewards of the staker and returns the amount
+func (e *RewardEngine) Claim(staker types.Address) (*big.Int, error) {
+	pending := e.pendingOf(staker)
+	if pending.Sign() == 0 {
+		return nil, ErrNoRewards
+	}
+
+	delete(e.Pending, staker)
+	e.Balance.Sub(e.Balance, pending)
+
+	return pending, nil
+}
+
+// CheckInvariants checks that the total staked amount is the sum of the stakes,
+// and that the contract balance covers exactly the stakes and the pending rewards
+func (e *RewardEngine) CheckInvariants() error {
+	sum := big.NewInt(0)
+	for _, stake := range e.Stakes {
+		sum.Add(sum, stake)
+	}
+
+	if sum.Cmp(e.TotalStaked) != 0 {
+		return fmt.Errorf("%w: total %s, sum %s", ErrTotalStakeMismatch, e.TotalStaked, sum)
+	}
+
+	for _, pending := range e.Pending {
+		sum.Add(sum, pending)
+	}
+
+	if sum.Cmp(e.Balance) != 0 {
+		return fmt.Errorf("%w: balance %s, expected %s", ErrBalanceMismatch, e.Balance, sum)
+	}
+
+	return nil
+}
+
+// stakeOf returns the stake of the staker, zero if it has none
+func (e *RewardEngine) stakeOf(staker types.Address) *big.Int {
+	if stake, ok := e.Stakes[staker]; ok {
+		return stake
+	}
+
+	return big.NewInt(0)
+}
+
+// pendingOf returns the pending rewards of the staker, zero if it has none
+func (e *RewardEngine) pendingOf(staker types.Address) *big.Int {
+	if pending, ok := e.Pending[staker]; ok {
+		return pending
+	}
+
+	return big.NewInt(0)
+}
+
+// ReadRewardEngine reads the reward engine state of the stakers from the staking contract storage.
+// The balance is the one of the staked asset held by the contract account
+func ReadRewardEngine(r StorageReader, stakers []types.Address, balance *big.Int) *RewardEngine {
+	e := &RewardEngine{
+		Stakes:       make(map[types.Address]*big.Int, len(stakers)),
+		TotalStaked:  readBig(r, getSlotHash(stakedAmountSlot)),
+		AutoCompound: make(map[types.Address]bool),
+		Pending:      make(map[types.Address]*big.Int),
+		Balance:      new(big.Int).Set(balance),
+	}
+
+	for _, staker := range stakers {
+		stake := readBig(r, types.BytesToHash(getAddressMapping(staker, addressToStakedAmountSlot)))
+		if stake.Sign() > 0 {
+			e.Stakes[staker] = stake
+		}
+
+		if readBig(r, types.BytesToHash(getAddressMapping(staker, addressToAutoCompoundSlot))).Sign() != 0 {
+			e.AutoCompound[staker] = true
+		}
+
+		pending := readBig(r, types.BytesToHash(getAddressMapping(staker, addressToPendingRewardSlot)))
+		if pending.Sign() > 0 {
+			e.Pending[staker] = pending
+		}
+	}
+
+	return e
+}
+
+// setAutoCompoundStorage sets the compounding flag of the genesis stakers into the genesis storage,
+// in address order
+func setAutoCompoundStorage(w StorageWriter, h *slotHasher, stakers map[types.Address]bool) {
+	addresses := make([]types.Address, 0, len(stakers))
+
+	for addr, enabled := range stakers {
+		if enabled {
+			addresses = append(addresses, addr)
+		}
+	}
+
+	sort.Slice(addresses, func(i, j int) bool {
+		return bytes.Compare(addresses[i].Bytes(), addresses[j].Bytes()) < 0
+	})
+
+	trueValue := types.BytesToHash(big.NewInt(1).Bytes())
+	autoCompoundSlot := getSlotHash(addressToAutoCompoundSlot)
+
+	for _, addr := range addresses {
+		w.SetStorage(h.addressMapping(addr, autoCompoundSlot), trueValue)
+	}
+}
+
+// validateGenesisAutoCompound checks that the compounding flags only refer to genesis stakers
+func validateGenesisAutoCompound(
+	stakers map[types.Address]bool,
+	genesisVals map[types.Address]bool,
+	candidates []GenesisCandidate,
+) error {
+	if len(stakers) == 0 {
+		return nil
+	}
+
+	genesisCandidates := make(map[types.Address]bool, len(candidates))
+	for _, candidate := range candidates {
+		genesisCandidates[candidate.Address] = true
+	}
+
+	for addr := range stakers {
+		if !genesisVals[addr] && !genesisCandidates[addr] {
+			return fmt.Errorf("auto-compound flag for %s, which is not a genesis staker", addr)
+		}
+	}
+
+	return nil
+}
+
+// AutoCompound gets the compounding flag of the staker from contract
+func (c *Client) AutoCompound(from types.Address, staker types.Address) (bool, error) {
+	outputs, err := c.callView(from, autoCompoundMethod, ethgo.Address(staker))
+	if err != nil {
+		return false, err
+	}
+
+	enabled, ok := outputs["enabled"].(bool)
+	if !ok {
+		return false, ErrFailedTypeAssertion
+	}
+
+	return enabled, nil
+}
+
+// SetAutoCompoundTx builds the transaction setting the compounding flag of the sender
+func (c *Client) SetAutoCompoundTx(from types.Address, nonce uint64, enabled bool) (*types.Transaction, error) {
+	return c.newStakingTx(from, nonce, big.NewInt(0), setAutoCompoundMethod, enabled)
+}
+
+// ClaimRewardsTx builds the transaction paying out the pending rewards of the sender
+func (c *Client) ClaimRewardsTx(from types.Address, nonce uint64) (*types.Transaction, error) {
+	return c.newStakingTx(from, nonce, big.NewInt(0), claimRewardsMethod)
+}
@@ -0,0 +1,221 @@
+package staking
+
+import (
+	"errors"
+	"math/big"
+
+	"github.com/0xPolygon/polygon-edge/types"
+)
+
+// Slot definitions for the epoch extension of the SC storage.
+// The epoch aware contract queues stake and unstake changes
+// and applies them to the validators array at the epoch boundary
+var (
+	epochSizeSlot      = int64(8)  // Slot 8
+	pendingChangesSlot = int64(9)  // Slot 9
+	pendingEpochSlot   = int64(10) // Slot 10
+)
+
+var (
+	ErrInvalidEpochSize     = errors.New("invalid epoch size")
+	ErrInvalidPendingChange = errors.New("invalid pending validator set change")
+	ErrBlockBeforeState     = errors.New("block is in an epoch before the staking state")
+)
+
+// ChangeType is the kind of a queued validator set change
+type ChangeType uint8
+
+const (
+	// ChangeAdd adds the address to the validators array
+	ChangeAdd ChangeType = iota + 1
+	// ChangeRemove removes the address from the validators array
+	ChangeRemove
+)
+
+// PendingChange is a validator set change waiting for the next epoch boundary.
+//
+// It is stored in a single slot as struct { address account; uint8 changeType; },
+// with the address in the lower 20 bytes and the change type in the byte above
+type PendingChange struct {
+	Type    ChangeType
+	Address types.Address
+}
+
+// decodePendingChange unpacks a pending change from its storage slot value
+func decodePendingChange(value types.Hash) (PendingChange, error) {
+	change := PendingChange{
+		Type:    ChangeType(value[types.HashLength-types.AddressLength-1]),
+		Address: types.BytesToAddress(value.Bytes()),
+	}
+
+	if change.Type != ChangeAdd && change.Type != ChangeRemove {
+		return PendingChange{}, ErrInvalidPendingChange
+	}
+
+	return change, nil
+}
+
+// EpochOf returns the epoch the block belongs to.
+// The genesis block is epoch 0, and every epoch ends with the block
+// whose number is a multiple of the epoch size.
+// An epoch size of 0 disables epochs, making every block its own epoch
+func EpochOf(blockNumber, epochSize uint64) uint64 {
+	if epochSize == 0 {
+		return blockNumber
+	}
+
+	if blockNumber%epochSize == 0 {
+		return blockNumber / epochSize
+	}
+
+	return blockNumber/epochSize + 1
+}
+
+// IsLastOfEpoch returns true if the block is the epoch boundary,
+// after which the pending changes take effect.
+// An epoch size of 0 disables epochs, making every block a boundary
+func IsLastOfEpoch(blockNumber, epochSize uint64) bool {
+	if epochSize == 0 {
+		return true
+	}
+
+	return blockNumber%epochSize == 0
+}
+
+// EpochState is the epoch related state of the staking contract
+type EpochState struct {
+	EpochSize    uint64          // Number of blocks in an epoch
+	Validators   []types.Address // Validators array, as of the last applied boundary
+	Pending      []PendingChange // Changes queued during PendingEpoch
+	PendingEpoch uint64          // Epoch in which the pending changes were queued
+}
+
+// ReadEpochState reads the epoch state from the staking contract storage
+func ReadEpochState(r StorageReader) (*EpochState, error) {
+	epochSize, ok := readUint64(r, getSlotHash(epochSizeSlot))
+	if !ok || epochSize == 0 {
+		return nil, ErrInvalidEpochSize
+	}
+
+	pendingEpoch, ok := readUint64(r, getSlotHash(pendingEpochSlot))
+	if !ok {
+		return nil, ErrInvalidPendingChange
+	}
+
+	vals, err := readValidators(r)
+	if err != nil {
+		return nil, err
+	}
+
+	pendingLen, ok := readUint64(r, getSlotHash(pendingChangesSlot))
+	if !ok || pendingLen > maxArrayLength {
+		return nil, ErrInvalidArrayLength
+	}
+
+	pending := make([]PendingChange, pendingLen)
+
+	for idx := uint64(0); idx < pendingLen; idx++ {
+		change, err := decodePendingChange(
+			r.GetStorage(getArrayElementIndex(pendingChangesSlot, idx)),
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		pending[idx] = change
+	}
+
+	return &EpochState{
+		EpochSize:    epochSize,
+		Validators:   vals,
+		Pending:      pending,
+		PendingEpoch: pendingEpoch,
+	}, nil
+}
+
+// ActiveValidators returns the validator set that is active for the given block.
+//
+// The set stays the same for the whole epoch: changes queued in an epoch
+// only become active from the first block of the following one.
+// The block must not be in an epoch before the one the state was read at
+func (s *EpochState) ActiveValidators(blockNumber uint64) ([]types.Address, error) {
+	epoch := EpochOf(blockNumber, s.EpochSize)
+
+	if epoch < s.PendingEpoch {
+		return nil, ErrBlockBeforeState
+	}
+
+	if epoch == s.PendingEpoch || len(s.Pending) == 0 {
+		return append([]types.Address(nil), s.Validators...), nil
+	}
+
+	return applyPendingChanges(s.Validators, s.Pending), nil
+}
+
+// ActiveValidatorsAt reads the epoch state from the staking contract storage
+// and returns the validator set that is active for the given block
+func ActiveValidatorsAt(r StorageReader, blockNumber uint64) ([]types.Address, error) {
+	state, err := ReadEpochState(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return state.ActiveValidators(blockNumber)
+}
+
+// Queue records a validator set change requested in the given block,
+// applying the already pending changes first if the block is in a later epoch
+func (s *EpochState) Queue(blockNumber uint64, change PendingChange) {
+	epoch := EpochOf(blockNumber, s.EpochSize)
+
+	if epoch > s.PendingEpoch {
+		s.Validators = applyPendingChanges(s.Validators, s.Pending)
+		s.Pending = nil
+		s.PendingEpoch = epoch
+	}
+
+	s.Pending = append(s.Pending, change)
+}
+
+// applyPendingChanges returns a copy of the validators array with the changes applied in order,
+// following the contract: additions are appended, and removals move the last
+// validator into the position of the removed one
+func applyPendingChanges(vals []types.Address, changes []PendingChange) []types.Address {
+	result := append([]types.Address(nil), vals...)
+
+	for _, change := range changes {
+		index := -1
+
+		for idx, addr := range result {
+			if addr == change.Address {
+				index = idx
+
+				break
+			}
+		}
+
+		switch change.Type {
+		case ChangeAdd:
+			if index < 0 {
+				result = append(result, change.Address)
+			}
+		case ChangeRemove:
+			if index >= 0 {
+				last := len(result) - 1
+				result[index] = result[last]
+				result = result[:last]
+			}
+		}
+	}
+
+	return result
+}
+
+// setEpochStorage sets the epoch slots of the genesis storage
+func setEpochStorage(w StorageWriter, epochSize uint64) {
+	// Set the epoch size; the pending changes array starts empty in epoch 0
+	w.SetStorage(
+		getSlotHash(epochSizeSlot),
+		types.BytesToHash(new(big.Int).SetUint64(epochSize).Bytes()),
+	)
+}
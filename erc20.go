@@ -0,0 +1,105 @@
+package staking
+
+import (
+	"errors"
+	"math/big"
+
+	"github.com/0xPolygon/polygon-edge/chain"
+	"github.com/0xPolygon/polygon-edge/types"
+)
+
+// Slot definitions for the ERC-20 staking contract.
+//
+// Its storage layout is the one of the native staking contract, slots 0 to 7, with the
+// staked amounts counted in the token instead of the native coin, and the token address
+// in its own slot 12. Slot 12 is reserved in the extension slot space shared by all
+// the versions (see the slot definitions of staking.go), so that the ERC-20 version can
+// be combined with the other extensions without moving any of their slots.
+// The stake is held in the token balance of the contract, see PrefundERC20Stake
+var (
+	stakingTokenSlot = int64(12) // Slot 12
+)
+
+var (
+	ErrNoTokenAccount   = errors.New("token genesis account is not set")
+	ErrNoStakingAccount = errors.New("staking genesis account is not set")
+	ErrNotERC20Staking  = errors.New("staking account doesn't stake an ERC-20 token")
+	ErrTokenSupplyUnder = errors.New("token total supply is lower than the balances")
+)
+
+// ERC20Layout is the storage layout of the staking token contract
+type ERC20Layout struct {
+	BalancesSlot    int64 // Slot of mapping(address => uint256) balances
+	TotalSupplySlot int64 // Slot of uint256 totalSupply
+}
+
+// OpenZeppelinERC20Layout is the storage layout of the OpenZeppelin ERC20 contract
+var OpenZeppelinERC20Layout = ERC20Layout{
+	BalancesSlot:    0,
+	TotalSupplySlot: 2,
+}
+
+// setStakingTokenStorage sets the token address into the genesis storage
+func setStakingTokenStorage(w StorageWriter, token types.Address) {
+	w.SetStorage(getSlotHash(stakingTokenSlot), types.BytesToHash(token.Bytes()))
+}
+
+// PrefundERC20Stake sets the token balance of the staking contract to its total stake
+// in the token genesis account, adjusting the total supply by the difference.
+//
+// The staking account must be the one returned by PredeployStakingSC for an ERC-20 token,
+// and the token account the one predeployed at the token address
+func PrefundERC20Stake(
+	token *chain.GenesisAccount,
+	layout ERC20Layout,
+	stakingAddress types.Address,
+	stakingAccount *chain.GenesisAccount,
+) error {
+	if token == nil {
+		return ErrNoTokenAccount
+	}
+
+	if stakingAccount == nil {
+		return ErrNoStakingAccount
+	}
+
+	stakingStorage := StorageMap(stakingAccount.Storage)
+	if readAddress(stakingStorage, getSlotHash(stakingTokenSlot)) == types.ZeroAddress {
+		return ErrNotERC20Staking
+	}
+
+	if token.Storage == nil {
+		token.Storage = make(map[types.Hash]types.Hash)
+	}
+
+	tokenStorage := StorageMap(token.Storage)
+	totalStake := readBig(stakingStorage, getSlotHash(stakedAmountSlot))
+
+	// Replace the current balance of the staking contract with the total stake
+	balanceIndex := types.BytesToHash(getAddressMapping(stakingAddress, layout.BalancesSlot))
+	totalSupplyIndex := getSlotHash(layout.TotalSupplySlot)
+
+	totalSupply := readBig(tokenStorage, totalSupplyIndex)
+	totalSupply.Sub(totalSupply, readBig(tokenStorage, balanceIndex))
+
+	if totalSupply.Sign() < 0 {
+		return ErrTokenSupplyUnder
+	}
+
+	totalSupply.Add(totalSupply, totalStake)
+
+	token.Storage[balanceIndex] = types.BytesToHash(totalStake.Bytes())
+	token.Storage[totalSupplyIndex] = types.BytesToHash(totalSupply.Bytes())
+
+	return nil
+}
+
+// totalStakeBalance returns the native balance of the staking account for the total stake.
+// ERC-20 staking holds the stake in token balance, so the native balance stays empty
+func totalStakeBalance(params *PredeployParams, stakedAmount *big.Int) *big.Int {
+	if params.StakingToken != types.ZeroAddress {
+		return big.NewInt(0)
+	}
+
+	return stakedAmount
+}
@@ -0,0 +1,235 @@
+package staking
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"regexp"
+	"sort"
+
+	"github.com/0xPolygon/polygon-edge/chain"
+	"github.com/0xPolygon/polygon-edge/helper/hex"
+	"github.com/0xPolygon/polygon-edge/types"
+)
+
+var (
+	ErrInvalidLibraryName = errors.New("invalid Solidity library name")
+
+	// solidityIdentifier matches the valid Solidity identifiers
+	solidityIdentifier = regexp.MustCompile(`^[a-zA-Z_$][a-zA-Z0-9_$]*$`)
+)
+
+// gethAccount is an account of the alloc of a geth genesis file
+type gethAccount struct {
+	Code    string                    `json:"code,omitempty"`
+	Storage map[types.Hash]types.Hash `json:"storage,omitempty"`
+	Balance string                    `json:"balance"`
+	Nonce   string                    `json:"nonce,omitempty"`
+}
+
+// anvilState is a state dump loaded by anvil with --load-state
+type anvilState struct {
+	Accounts map[types.Address]anvilAccount `json:"accounts"`
+}
+
+// anvilAccount is an account of an anvil state dump
+type anvilAccount struct {
+	Nonce   uint64                    `json:"nonce"`
+	Balance string                    `json:"balance"`
+	Code    string                    `json:"code"`
+	Storage map[types.Hash]types.Hash `json:"storage"`
+}
+
+// rpcRequest is a JSON-RPC request
+type rpcRequest struct {
+	JSONRPC string        `json:"jsonrpc"`
+	ID      int           `json:"id"`
+	Method  string        `json:"method"`
+	Params  []interface{} `json:"params"`
+}
+
+// ExportGethAlloc writes the genesis accounts as the alloc of a geth genesis file
+func ExportGethAlloc(out io.Writer, accounts map[types.Address]*chain.GenesisAccount) error {
+	alloc := make(map[types.Address]gethAccount, len(accounts))
+
+	for addr, account := range accounts {
+		entry := gethAccount{
+			Storage: account.Storage,
+			Balance: hex.EncodeBig(balanceOf(account)),
+		}
+
+		if len(account.Code) > 0 {
+			entry.Code = hex.EncodeToHex(account.Code)
+		}
+
+		if account.Nonce != 0 {
+			entry.Nonce = hex.EncodeUint64(account.Nonce)
+		}
+
+		alloc[addr] = entry
+	}
+
+	return writeIndentedJSON(out, alloc)
+}
+
+// ExportAnvilState writes the genesis accounts as an anvil state dump, to be loaded
+// with anvil --load-state
+func ExportAnvilState(out io.Writer, accounts map[types.Address]*chain.GenesisAccount) error {
+	dump := anvilState{
+		Accounts: make(map[types.Address]anvilAccount, len(accounts)),
+	}
+
+	for addr, account := range accounts {
+		storage := account.Storage
+		if storage == nil {
+			storage = map[types.Hash]types.Hash{}
+		}
+
+		dump.Accounts[addr] = anvilAccount{
+			Nonce:   account.Nonce,
+			Balance: hex.EncodeBig(balanceOf(account)),
+			Code:    hex.EncodeToHex(account.Code),
+			Storage: storage,
+		}
+	}
+
+	return writeIndentedJSON(out, dump)
+}
+
+// ExportHardhatRPC writes the genesis accounts as a JSON array of the hardhat_setCode,
+// hardhat_setBalance, hardhat_setNonce and hardhat_setStorageAt requests setting them up
+// on a running hardhat or anvil node. The accounts and storage slots are in ascending order
+func ExportHardhatRPC(out io.Writer, accounts map[types.Address]*chain.GenesisAccount) error {
+	requests := make([]rpcRequest, 0)
+	add := func(method string, params ...interface{}) {
+		requests = append(requests, rpcRequest{
+			JSONRPC: "2.0",
+			ID:      len(requests) + 1,
+			Method:  method,
+			Params:  params,
+		})
+	}
+
+	for _, addr := range sortedAccounts(accounts) {
+		account := accounts[addr]
+
+		add("hardhat_setCode", addr, hex.EncodeToHex(account.Code))
+		add("hardhat_setBalance", addr, hex.EncodeBig(balanceOf(account)))
+
+		if account.Nonce != 0 {
+			add("hardhat_setNonce", addr, hex.EncodeUint64(account.Nonce))
+		}
+
+		for _, key := range sortedStorageKeys(account.Storage) {
+			// The slot is a quantity, without leading zeros
+			add("hardhat_setStorageAt", addr, hex.EncodeBig(new(big.Int).SetBytes(key.Bytes())), account.Storage[key])
+		}
+	}
+
+	return writeIndentedJSON(out, requests)
+}
+
+// ExportFoundryScript writes the genesis accounts as a Solidity library for foundry tests and scripts,
+// whose setUp function etches the code, deals the balance and stores the storage of every account
+// with the forge-std cheatcodes. The accounts and storage slots are in ascending order
+func ExportFoundryScript(out io.Writer, name string, accounts map[types.Address]*chain.GenesisAccount) error {
+	if !solidityIdentifier.MatchString(name) {
+		return fmt.Errorf("%w: %q", ErrInvalidLibraryName, name)
+	}
+
+	w := &countingWriter{w: bufio.NewWriter(out)}
+
+	w.writeString("// SPDX-License-Identifier: UNLICENSED\n")
+	w.writeString("pragma solidity ^0.8.0;\n\n")
+	w.writeString("import {Vm} from \"forge-std/Vm.sol\";\n\n")
+	w.writeString("library " + name + " {\n")
+	w.writeString("    Vm private constant vm = Vm(address(uint160(uint256(keccak256(\"hevm cheat code\")))));\n\n")
+	w.writeString("    function setUp() internal {\n")
+
+	for idx, addr := range sortedAccounts(accounts) {
+		account := accounts[addr]
+
+		if idx > 0 {
+			w.writeString("\n")
+		}
+
+		// Address literals are checksummed, as Solidity requires
+		w.writeString(fmt.Sprintf("        vm.etch(%s, hex\"%s\");\n", addr, hex.EncodeToString(account.Code)))
+		w.writeString(fmt.Sprintf("        vm.deal(%s, %s);\n", addr, balanceOf(account)))
+
+		if account.Nonce != 0 {
+			w.writeString(fmt.Sprintf("        vm.setNonce(%s, %d);\n", addr, account.Nonce))
+		}
+
+		for _, key := range sortedStorageKeys(account.Storage) {
+			w.writeString(fmt.Sprintf(
+				"        vm.store(%s, bytes32(%s), bytes32(%s));\n",
+				addr,
+				key,
+				account.Storage[key],
+			))
+		}
+	}
+
+	w.writeString("    }\n")
+	w.writeString("}\n")
+
+	if w.err == nil {
+		w.err = w.w.Flush()
+	}
+
+	return w.err
+}
+
+// balanceOf returns the balance of the genesis account, zero if it has none
+func balanceOf(account *chain.GenesisAccount) *big.Int {
+	if account.Balance == nil {
+		return big.NewInt(0)
+	}
+
+	return account.Balance
+}
+
+// sortedAccounts returns the addresses of the accounts in ascending order
+func sortedAccounts(accounts map[types.Address]*chain.GenesisAccount) []types.Address {
+	addresses := make([]types.Address, 0, len(accounts))
+	for addr := range accounts {
+		addresses = append(addresses, addr)
+	}
+
+	sort.Slice(addresses, func(i, j int) bool {
+		return bytes.Compare(addresses[i].Bytes(), addresses[j].Bytes()) < 0
+	})
+
+	return addresses
+}
+
+// sortedStorageKeys returns the keys of the storage in ascending order
+func sortedStorageKeys(storage map[types.Hash]types.Hash) []types.Hash {
+	keys := make([]types.Hash, 0, len(storage))
+	for key := range storage {
+		keys = append(keys, key)
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		return bytes.Compare(keys[i].Bytes(), keys[j].Bytes()) < 0
+	})
+
+	return keys
+}
+
+// writeIndentedJSON writes the value as indented JSON, ending with a newline
+func writeIndentedJSON(out io.Writer, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	_, err = out.Write(append(data, '\n'))
+
+	return err
+}
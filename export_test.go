@@ -0,0 +1,235 @@
+package staking
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/0xPolygon/polygon-edge/chain"
+	"github.com/0xPolygon/polygon-edge/types"
+)
+
+var (
+	testExportContract = types.StringToAddress("0x02")
+	testExportAccount  = types.StringToAddress("0x01")
+
+	errTestWrite = errors.New("write failed")
+)
+
+// testExportAccounts returns a contract account with storage and a nonce,
+// and an account without balance, code or storage
+func testExportAccounts() map[types.Address]*chain.GenesisAccount {
+	return map[types.Address]*chain.GenesisAccount{
+		testExportContract: {
+			Code:    []byte{0x60, 0x80},
+			Balance: big.NewInt(256),
+			Nonce:   1,
+			Storage: map[types.Hash]types.Hash{
+				types.StringToHash("0x10"): types.StringToHash("0xaa"),
+				types.StringToHash("0x02"): types.StringToHash("0xbb"),
+			},
+		},
+		testExportAccount: {},
+	}
+}
+
+// errWriter fails every write
+type errWriter struct{}
+
+func (errWriter) Write([]byte) (int, error) {
+	return 0, errTestWrite
+}
+
+func TestExportGethAlloc(t *testing.T) {
+	t.Parallel()
+
+	out := &bytes.Buffer{}
+	if err := ExportGethAlloc(out, testExportAccounts()); err != nil {
+		t.Fatal(err)
+	}
+
+	alloc := map[types.Address]map[string]interface{}{}
+	if err := json.Unmarshal(out.Bytes(), &alloc); err != nil {
+		t.Fatal(err)
+	}
+
+	contract := alloc[testExportContract]
+	if contract["code"] != "0x6080" || contract["balance"] != "0x100" || contract["nonce"] != "0x1" {
+		t.Fatalf("unexpected contract account %v", contract)
+	}
+
+	storage, ok := contract["storage"].(map[string]interface{})
+	if !ok || len(storage) != 2 || storage[types.StringToHash("0x10").String()] != types.StringToHash("0xaa").String() {
+		t.Fatalf("unexpected contract storage %v", contract["storage"])
+	}
+
+	// Only the balance is required
+	account := alloc[testExportAccount]
+	if len(account) != 1 || account["balance"] != "0x0" {
+		t.Fatalf("unexpected empty account %v", account)
+	}
+}
+
+func TestExportAnvilState(t *testing.T) {
+	t.Parallel()
+
+	out := &bytes.Buffer{}
+	if err := ExportAnvilState(out, testExportAccounts()); err != nil {
+		t.Fatal(err)
+	}
+
+	dump := anvilState{}
+	if err := json.Unmarshal(out.Bytes(), &dump); err != nil {
+		t.Fatal(err)
+	}
+
+	contract := dump.Accounts[testExportContract]
+	if contract.Code != "0x6080" || contract.Balance != "0x100" || contract.Nonce != 1 || len(contract.Storage) != 2 {
+		t.Fatalf("unexpected contract account %+v", contract)
+	}
+
+	// Anvil requires every field, with an empty storage object rather than null
+	if !strings.Contains(out.String(), `"storage": {}`) {
+		t.Fatalf("expected an empty storage object in %s", out)
+	}
+
+	account := dump.Accounts[testExportAccount]
+	if account.Code != "0x" || account.Balance != "0x0" || account.Nonce != 0 {
+		t.Fatalf("unexpected empty account %+v", account)
+	}
+}
+
+func TestExportHardhatRPC(t *testing.T) {
+	t.Parallel()
+
+	out := &bytes.Buffer{}
+	if err := ExportHardhatRPC(out, testExportAccounts()); err != nil {
+		t.Fatal(err)
+	}
+
+	var requests []struct {
+		JSONRPC string   `json:"jsonrpc"`
+		ID      int      `json:"id"`
+		Method  string   `json:"method"`
+		Params  []string `json:"params"`
+	}
+
+	if err := json.Unmarshal(out.Bytes(), &requests); err != nil {
+		t.Fatal(err)
+	}
+
+	account, contract := testExportAccount.String(), testExportContract.String()
+
+	// The accounts and the slots are in ascending order, the slots without leading zeros
+	expected := [][]string{
+		{"hardhat_setCode", account, "0x"},
+		{"hardhat_setBalance", account, "0x0"},
+		{"hardhat_setCode", contract, "0x6080"},
+		{"hardhat_setBalance", contract, "0x100"},
+		{"hardhat_setNonce", contract, "0x1"},
+		{"hardhat_setStorageAt", contract, "0x2", types.StringToHash("0xbb").String()},
+		{"hardhat_setStorageAt", contract, "0x10", types.StringToHash("0xaa").String()},
+	}
+
+	if len(requests) != len(expected) {
+		t.Fatalf("expected %d requests, got %d", len(expected), len(requests))
+	}
+
+	for idx, request := range requests {
+		call := append([]string{request.Method}, request.Params...)
+
+		if request.JSONRPC != "2.0" || request.ID != idx+1 || strings.Join(call, " ") != strings.Join(expected[idx], " ") {
+			t.Fatalf("unexpected request %d: %+v", idx, request)
+		}
+	}
+}
+
+func TestExportFoundryScript(t *testing.T) {
+	t.Parallel()
+
+	out := &bytes.Buffer{}
+	if err := ExportFoundryScript(out, "Genesis", testExportAccounts()); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `// SPDX-License-Identifier: UNLICENSED
+pragma solidity ^0.8.0;
+
+import {Vm} from "forge-std/Vm.sol";
+
+library Genesis {
+    Vm private constant vm = Vm(address(uint160(uint256(keccak256("hevm cheat code")))));
+
+    function setUp() internal {
+        vm.etch(0x0000000000000000000000000000000000000001, hex"");
+        vm.deal(0x0000000000000000000000000000000000000001, 0);
+
+        vm.etch(0x0000000000000000000000000000000000000002, hex"6080");
+        vm.deal(0x0000000000000000000000000000000000000002, 256);
+        vm.setNonce(0x0000000000000000000000000000000000000002, 1);
+        vm.store(0x0000000000000000000000000000000000000002, bytes32(` +
+		`0x0000000000000000000000000000000000000000000000000000000000000002), bytes32(` +
+		`0x00000000000000000000000000000000000000000000000000000000000000bb));
+        vm.store(0x0000000000000000000000000000000000000002, bytes32(` +
+		`0x0000000000000000000000000000000000000000000000000000000000000010), bytes32(` +
+		`0x00000000000000000000000000000000000000000000000000000000000000aa));
+    }
+}
+`
+
+	if out.String() != expected {
+		t.Fatalf("unexpected script:\n%s", out)
+	}
+}
+
+func TestExportFoundryScriptInvalidName(t *testing.T) {
+	t.Parallel()
+
+	for _, name := range []string{"", "1Genesis", "Genesis-State", "Genesis State", "Genesis;"} {
+		name := name
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			out := &bytes.Buffer{}
+			if err := ExportFoundryScript(out, name, testExportAccounts()); !errors.Is(err, ErrInvalidLibraryName) {
+				t.Fatalf("expected %v, got %v", ErrInvalidLibraryName, err)
+			}
+
+			if out.Len() != 0 {
+				t.Fatalf("expected no output, got %s", out)
+			}
+		})
+	}
+}
+
+func TestExportWriteError(t *testing.T) {
+	t.Parallel()
+
+	accounts := testExportAccounts()
+
+	testTable := []struct {
+		name   string
+		export func() error
+	}{
+		{"geth", func() error { return ExportGethAlloc(errWriter{}, accounts) }},
+		{"anvil", func() error { return ExportAnvilState(errWriter{}, accounts) }},
+		{"hardhat", func() error { return ExportHardhatRPC(errWriter{}, accounts) }},
+		{"foundry", func() error { return ExportFoundryScript(errWriter{}, "Genesis", accounts) }},
+	}
+
+	for _, test := range testTable {
+		test := test
+
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			if err := test.export(); !errors.Is(err, errTestWrite) {
+				t.Fatalf("expected %v, got %v", errTestWrite, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,500 @@
+package staking
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+
+	"github.com/0xPolygon/polygon-edge/types"
+	"github.com/umbracle/ethgo"
+	"github.com/umbracle/ethgo/abi"
+)
+
+// Events emitted by the staking contract
+var (
+	stakedEvent                 = abi.MustNewEvent("event Staked(address indexed account, uint256 amount)")
+	unstakedEvent               = abi.MustNewEvent("event Unstaked(address indexed account, uint256 amount)")
+	blsPublicKeyRegisteredEvent = abi.MustNewEvent("event BLSPublicKeyRegistered(address indexed account, bytes key)")
+)
+
+// defaultReorgDepth is the number of recent blocks the feed can roll back
+const defaultReorgDepth = 64
+
+// subscriptionBufferSize is the capacity of the subscription channels
+const subscriptionBufferSize = 64
+
+var (
+	ErrReorgTooDeep   = errors.New("reorg is deeper than the tracked blocks")
+	ErrBlockNotLinked = errors.New("block doesn't extend any tracked block")
+)
+
+// ValidatorSetChangeType is the kind of a validator set change
+type ValidatorSetChangeType uint8
+
+const (
+	ValidatorAdded ValidatorSetChangeType = iota + 1
+	ValidatorRemoved
+	BLSKeyChanged
+)
+
+// String returns the name of the change type
+func (t ValidatorSetChangeType) String() string {
+	switch t {
+	case ValidatorAdded:
+		return "ValidatorAdded"
+	case ValidatorRemoved:
+		return "ValidatorRemoved"
+	case BLSKeyChanged:
+		return "BLSKeyChanged"
+	default:
+		return fmt.Sprintf("ValidatorSetChangeType(%d)", uint8(t))
+	}
+}
+
+// ValidatorSetChange is a change of the staking contract validator set.
+//
+// When a reorg drops the block that made the change, the feed emits a reversal:
+// the opposite change with Reverted set, referring to the dropped block
+type ValidatorSetChange struct {
+	Type         ValidatorSetChangeType
+	Address      types.Address
+	BLSPublicKey []byte // Key after the change, for BLSKeyChanged
+	BlockNumber  uint64
+	BlockHash    types.Hash
+	Reverted     bool
+}
+
+// accountState is the state of a staker tracked by the feed
+type accountState struct {
+	stake        *big.Int
+	isValidator  bool
+	blsPublicKey []byte
+}
+
+// processedBlock keeps what is needed to roll back a processed block
+type processedBlock struct {
+	number     uint64
+	hash       types.Hash
+	parentHash types.Hash
+	changes    []ValidatorSetChange
+	reversals  []ValidatorSetChange           // Reversal of each change
+	previous   map[types.Address]accountState // Accounts before the block
+}
+
+// ChangeFeedConfig is the initial state of the change feed
+type ChangeFeedConfig struct {
+	Contract   types.Address              // Address of the staking contract
+	Threshold  *big.Int                   // Validator stake threshold, defaults to DefaultStakedBalance
+	Head       *types.Header              // Block the initial state is taken at
+	Validators []types.Address            // Validators at the head block
+	Stakes     map[types.Address]*big.Int // Stakes at the head block, including non-validators
+	BLSKeys    map[types.Address][]byte   // Registered BLS keys at the head block
+	ReorgDepth int                        // Recent blocks kept for reorgs, defaults to 64
+}
+
+// ChangeFeed follows the staking contract events in the receipts of new blocks
+// and notifies its subscribers of the validator set changes.
+//
+// It mirrors the contract: a staker becomes a validator once its stake reaches the threshold,
+// and stops being one when it unstakes, or when a partial unstake leaves it below the threshold
+type ChangeFeed struct {
+	lock sync.Mutex
+
+	// deliveryLock keeps the changes of consecutive blocks in order,
+	// as they are delivered once the state lock is released
+	deliveryLock sync.Mutex
+
+	contract   types.Address
+	threshold  *big.Int
+	accounts   map[types.Address]accountState
+	history    []*processedBlock // Recent blocks, oldest first
+	headHash   types.Hash        // Hash of the last processed block
+	reorgDepth int
+
+	subscriptions map[*Subscription]struct{}
+}
+
+// Subscription receives the validator set changes emitted by the feed
+type Subscription struct {
+	changeCh chan ValidatorSetChange
+	closeCh  chan struct{}
+	once     sync.Once
+}
+
+// Changes returns the channel the changes are delivered on
+func (s *Subscription) Changes() <-chan ValidatorSetChange {
+	return s.changeCh
+}
+
+// close stops the delivery of changes to the subscription
+func (s *Subscription) close() {
+	s.once.Do(func() {
+		close(s.closeCh)
+	})
+}
+
+// NewChangeFeed creates a change feed starting from the given state
+func NewChangeFeed(config ChangeFeedConfig) (*ChangeFeed, error) {
+	if config.Head == nil {
+		return nil, errors.New("head block is required")
+	}
+
+	threshold := config.Threshold
+	if threshold == nil {
+		val := DefaultStakedBalance
+
+		var err error
+		if threshold, err = types.ParseUint256orHex(&val); err != nil {
+			return nil, err
+		}
+	}
+
+	reorgDepth := config.ReorgDepth
+	if reorgDepth <= 0 {
+		reorgDepth = defaultReorgDepth
+	}
+
+	accounts := make(map[types.Address]accountState)
+
+	for addr, stake := range config.Stakes {
+		accounts[addr] = accountState{stake: new(big.Int).Set(stake)}
+	}
+
+	for addr, key := range config.BLSKeys {
+		account := accounts[addr]
+		account.blsPublicKey = key
+		accounts[addr] = account
+	}
+
+	for _, addr := range config.Validators {
+		account := accounts[addr]
+		account.isValidator = true
+		accounts[addr] = account
+	}
+
+	return &ChangeFeed{
+		contract:      config.Contract,
+		threshold:     threshold,
+		accounts:      accounts,
+		headHash:      config.Head.Hash,
+		reorgDepth:    reorgDepth,
+		subscriptions: make(map[*Subscription]struct{}),
+	}, nil
+}
+
+// Subscribe creates a subscription to the changes processed from now on.
+// The subscriber must keep draining the channel, as the feed waits for the delivery.
+// Changes are delivered without holding the state of the feed, so the subscriber
+// can query it, such as with IsValidator, while handling them
+func (f *ChangeFeed) Subscribe() *Subscription {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	sub := &Subscription{
+		changeCh: make(chan ValidatorSetChange, subscriptionBufferSize),
+		closeCh:  make(chan struct{}),
+	}
+
+	f.subscriptions[sub] = struct{}{}
+
+	return sub
+}
+
+// Unsubscribe stops the delivery of changes to the subscription
+func (f *ChangeFeed) Unsubscribe(sub *Subscription) {
+	sub.close()
+
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	delete(f.subscriptions, sub)
+}
+
+// IsValidator returns true if the address is in the validator set as of the last processed block
+func (f *ChangeFeed) IsValidator(addr types.Address) bool {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	return f.accounts[addr].isValidator
+}
+
+// ProcessBlock processes the receipts of a new block and emits the validator set changes.
+//
+// If the block doesn't extend the last processed one, the blocks dropped by the reorg
+// are rolled back first, emitting the reversals of their changes
+func (f *ChangeFeed) ProcessBlock(header *types.Header, receipts []*types.Receipt) error {
+	f.lock.Lock()
+
+	changes, err := f.processBlock(header, receipts)
+
+	subscriptions := make([]*Subscription, 0, len(f.subscriptions))
+	for sub := range f.subscriptions {
+		subscriptions = append(subscriptions, sub)
+	}
+
+	// Take the delivery lock before releasing the state,
+	// so that the next block can't deliver its changes first
+	f.deliveryLock.Lock()
+	defer f.deliveryLock.Unlock()
+
+	f.lock.Unlock()
+
+	for _, change := range changes {
+		for _, sub := range subscriptions {
+			sub.deliver(change)
+		}
+	}
+
+	return err
+}
+
+// processBlock applies the block to the tracked accounts and returns the changes to emit,
+// which are the reversals of a rollback even if the block itself fails
+func (f *ChangeFeed) processBlock(header *types.Header, receipts []*types.Receipt) ([]ValidatorSetChange, error) {
+	changes, err := f.rollbackTo(header.ParentHash)
+	if err != nil {
+		return changes, err
+	}
+
+	block := &processedBlock{
+		number:     header.Number,
+		hash:       header.Hash,
+		parentHash: header.ParentHash,
+		previous:   make(map[types.Address]accountState),
+	}
+
+	for _, receipt := range receipts {
+		if receipt.Status != nil && *receipt.Status == types.ReceiptFailed {
+			continue
+		}
+
+		for _, log := range receipt.Logs {
+			if log.Address != f.contract {
+				continue
+			}
+
+			if err := f.processLog(block, log); err != nil {
+				// Leave the accounts as they were before the block
+				for addr, account := range block.previous {
+					f.accounts[addr] = account
+				}
+
+				return changes, err
+			}
+		}
+	}
+
+	f.history = append(f.history, block)
+	if len(f.history) > f.reorgDepth {
+		f.history = f.history[1:]
+	}
+
+	f.headHash = header.Hash
+
+	return append(changes, block.changes...), nil
+}
+
+// processLog applies the staking contract event to the tracked accounts
+func (f *ChangeFeed) processLog(block *processedBlock, log *types.Log) error {
+	ethLog := toEthgoLog(log)
+
+	switch {
+	case stakedEvent.Match(ethLog):
+		values, err := stakedEvent.ParseLog(ethLog)
+		if err != nil {
+			return err
+		}
+
+		addr, amount, err := decodeStakeEvent(values)
+		if err != nil {
+			return err
+		}
+
+		account := f.update(block, addr)
+		account.stake = new(big.Int).Add(account.stake, amount)
+
+		if !account.isValidator && account.stake.Cmp(f.threshold) >= 0 {
+			account.isValidator = true
+			block.record(ValidatorAdded, ValidatorRemoved, addr, nil, nil)
+		}
+
+		f.accounts[addr] = account
+	case unstakedEvent.Match(ethLog):
+		values, err := unstakedEvent.ParseLog(ethLog)
+		if err != nil {
+			return err
+		}
+
+		addr, _, err := decodeStakeEvent(values)
+		if err != nil {
+			return err
+		}
+
+		// Unstaking always withdraws the whole stake
+		account := f.update(block, addr)
+		account.stake = big.NewInt(0)
+
+		if account.isValidator {
+			account.isValidator = false
+			block.record(ValidatorRemoved, ValidatorAdded, addr, nil, nil)
+		}
+
+		f.accounts[addr] = account
+	case stakeIncreasedEvent.Match(ethLog), stakeDecreasedEvent.Match(ethLog):
+		// The log was filtered on the feed contract, which may be any instance
+		change, err := decodeStakeChange(ethLog)
+		if err != nil {
+			return err
+		}
+
+		// The partial unstake contract emits the resulting stake
+		account := f.update(block, change.Account)
+		account.stake = change.Balance
+
+		switch {
+		case !account.isValidator && account.stake.Cmp(f.threshold) >= 0:
+			account.isValidator = true
+			block.record(ValidatorAdded, ValidatorRemoved, change.Account, nil, nil)
+		case account.isValidator && account.stake.Cmp(f.threshold) < 0:
+			account.isValidator = false
+			block.record(ValidatorRemoved, ValidatorAdded, change.Account, nil, nil)
+		}
+
+		f.accounts[change.Account] = account
+	case blsPublicKeyRegisteredEvent.Match(ethLog):
+		values, err := blsPublicKeyRegisteredEvent.ParseLog(ethLog)
+		if err != nil {
+			return err
+		}
+
+		addr, ok := values["account"].(ethgo.Address)
+		if !ok {
+			return ErrFailedTypeAssertion
+		}
+
+		key, ok := values["key"].([]byte)
+		if !ok {
+			return ErrFailedTypeAssertion
+		}
+
+		account := f.update(block, types.Address(addr))
+		block.record(BLSKeyChanged, BLSKeyChanged, types.Address(addr), key, account.blsPublicKey)
+
+		account.blsPublicKey = key
+		f.accounts[types.Address(addr)] = account
+	}
+
+	return nil
+}
+
+// update returns a copy of the account to modify,
+// saving its state before the block for rollbacks
+func (f *ChangeFeed) update(block *processedBlock, addr types.Address) accountState {
+	account := f.accounts[addr]
+
+	if _, saved := block.previous[addr]; !saved {
+		block.previous[addr] = account
+	}
+
+	if account.stake == nil {
+		account.stake = big.NewInt(0)
+	}
+
+	return account
+}
+
+// record adds the change to the changes made by the block, along with its reversal
+func (b *processedBlock) record(
+	changeType ValidatorSetChangeType,
+	reversalType ValidatorSetChangeType,
+	addr types.Address,
+	key []byte,
+	previousKey []byte,
+) {
+	change := ValidatorSetChange{
+		Type:         changeType,
+		Address:      addr,
+		BLSPublicKey: key,
+		BlockNumber:  b.number,
+		BlockHash:    b.hash,
+	}
+
+	reversal := change
+	reversal.Type = reversalType
+	reversal.BLSPublicKey = previousKey
+	reversal.Reverted = true
+
+	b.changes = append(b.changes, change)
+	b.reversals = append(b.reversals, reversal)
+}
+
+// rollbackTo rolls back the processed blocks until the one with the given hash is the head,
+// returning the reversals of their changes
+func (f *ChangeFeed) rollbackTo(hash types.Hash) ([]ValidatorSetChange, error) {
+	if f.headHash == hash {
+		return nil, nil
+	}
+
+	// Find the block to go back to before touching any state
+	depth := -1
+
+	for idx := len(f.history) - 1; idx >= 0; idx-- {
+		if f.history[idx].parentHash == hash {
+			depth = len(f.history) - idx
+
+			break
+		}
+	}
+
+	if depth < 0 {
+		if len(f.history) == f.reorgDepth {
+			return nil, ErrReorgTooDeep
+		}
+
+		return nil, ErrBlockNotLinked
+	}
+
+	reversals := make([]ValidatorSetChange, 0)
+
+	for ; depth > 0; depth-- {
+		block := f.history[len(f.history)-1]
+		f.history = f.history[:len(f.history)-1]
+
+		for addr, account := range block.previous {
+			f.accounts[addr] = account
+		}
+
+		// Undo the changes of the block in reverse order
+		for idx := len(block.reversals) - 1; idx >= 0; idx-- {
+			reversals = append(reversals, block.reversals[idx])
+		}
+	}
+
+	f.headHash = hash
+
+	return reversals, nil
+}
+
+// deliver waits until the change is delivered, or the subscription is closed
+func (s *Subscription) deliver(change ValidatorSetChange) {
+	select {
+	case s.changeCh <- change:
+	case <-s.closeCh:
+	}
+}
+
+// decodeStakeEvent returns the account and the amount of a Staked or Unstaked event
+func decodeStakeEvent(values map[string]interface{}) (types.Address, *big.Int, error) {
+	addr, ok := values["account"].(ethgo.Address)
+	if !ok {
+		return types.ZeroAddress, nil, ErrFailedTypeAssertion
+	}
+
+	amount, err := decodeBig(values, "amount")
+	if err != nil {
+		return types.ZeroAddress, nil, err
+	}
+
+	return types.Address(addr), amount, nil
+}
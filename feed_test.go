@@ -0,0 +1,334 @@
+package staking
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/0xPolygon/polygon-edge/types"
+	"github.com/umbracle/ethgo/abi"
+)
+
+var (
+	feedContract = types.StringToAddress("1001")
+	feedGenesis  = types.StringToHash("genesis")
+	feedStake    = new(big.Int).Mul(big.NewInt(10), big.NewInt(1e18))
+)
+
+// stakeLog returns a Staked or Unstaked log of the account
+func stakeLog(event *abi.Event, account types.Address, amount *big.Int) *types.Log {
+	return &types.Log{
+		Address: feedContract,
+		Topics: []types.Hash{
+			types.Hash(event.ID()),
+			types.BytesToHash(account.Bytes()),
+		},
+		Data: types.BytesToHash(amount.Bytes()).Bytes(),
+	}
+}
+
+// feedBlock returns the header and receipts of a block with the logs
+func feedBlock(number uint64, hash, parent string, logs ...*types.Log) (*types.Header, []*types.Receipt) {
+	parentHash := feedGenesis
+	if parent != "" {
+		parentHash = types.StringToHash(parent)
+	}
+
+	return &types.Header{
+		Number:     number,
+		Hash:       types.StringToHash(hash),
+		ParentHash: parentHash,
+	}, []*types.Receipt{{Logs: logs}}
+}
+
+// newTestFeed creates a feed at the genesis block with no stakers
+func newTestFeed(t *testing.T, reorgDepth int) *ChangeFeed {
+	t.Helper()
+
+	feed, err := NewChangeFeed(ChangeFeedConfig{
+		Contract:   feedContract,
+		Head:       &types.Header{Hash: feedGenesis},
+		ReorgDepth: reorgDepth,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return feed
+}
+
+// receive returns the next n changes of the subscription
+func receive(t *testing.T, sub *Subscription, n int) []ValidatorSetChange {
+	t.Helper()
+
+	changes := make([]ValidatorSetChange, 0, n)
+
+	for len(changes) < n {
+		select {
+		case change := <-sub.Changes():
+			changes = append(changes, change)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("received %d changes, expected %d", len(changes), n)
+		}
+	}
+
+	return changes
+}
+
+// expectNoChanges checks that the subscription has no change waiting
+func expectNoChanges(t *testing.T, sub *Subscription) {
+	t.Helper()
+
+	select {
+	case change := <-sub.Changes():
+		t.Fatalf("unexpected change %+v", change)
+	default:
+	}
+}
+
+// checkChange checks the type, address and reversal flag of the change
+func checkChange(
+	t *testing.T,
+	change ValidatorSetChange,
+	changeType ValidatorSetChangeType,
+	addr types.Address,
+	reverted bool,
+) {
+	t.Helper()
+
+	if change.Type != changeType || change.Address != addr || change.Reverted != reverted {
+		t.Fatalf(
+			"got %s %s (reverted %t), expected %s %s (reverted %t)",
+			change.Type, change.Address, change.Reverted,
+			changeType, addr, reverted,
+		)
+	}
+}
+
+func TestChangeFeedReorg(t *testing.T) {
+	t.Parallel()
+
+	feed := newTestFeed(t, 0)
+	sub := feed.Subscribe()
+
+	a, b := types.StringToAddress("a"), types.StringToAddress("b")
+
+	// Block 1 adds A
+	if err := feed.ProcessBlock(feedBlock(1, "1", "", stakeLog(stakedEvent, a, feedStake))); err != nil {
+		t.Fatal(err)
+	}
+
+	checkChange(t, receive(t, sub, 1)[0], ValidatorAdded, a, false)
+
+	// Block 1' replaces block 1 and adds B instead
+	if err := feed.ProcessBlock(feedBlock(1, "1'", "", stakeLog(stakedEvent, b, feedStake))); err != nil {
+		t.Fatal(err)
+	}
+
+	changes := receive(t, sub, 2)
+	checkChange(t, changes[0], ValidatorRemoved, a, true)
+	checkChange(t, changes[1], ValidatorAdded, b, false)
+
+	if changes[0].BlockHash != types.StringToHash("1") {
+		t.Fatalf("reversal refers to block %s, expected the dropped block", changes[0].BlockHash)
+	}
+
+	if feed.IsValidator(a) || !feed.IsValidator(b) {
+		t.Fatal("validator set doesn't follow the reorg")
+	}
+
+	expectNoChanges(t, sub)
+}
+
+func TestChangeFeedRollbackSeveralBlocks(t *testing.T) {
+	t.Parallel()
+
+	feed := newTestFeed(t, 0)
+	sub := feed.Subscribe()
+
+	a, b, c := types.StringToAddress("a"), types.StringToAddress("b"), types.StringToAddress("c")
+
+	for _, block := range []struct {
+		number       uint64
+		hash, parent string
+		log          *types.Log
+	}{
+		{1, "1", "", stakeLog(stakedEvent, a, feedStake)},
+		{2, "2", "1", stakeLog(stakedEvent, b, feedStake)},
+		{3, "3", "2", stakeLog(unstakedEvent, a, feedStake)},
+	} {
+		if err := feed.ProcessBlock(feedBlock(block.number, block.hash, block.parent, block.log)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	receive(t, sub, 3)
+
+	// A fork from block 1 drops blocks 2 and 3, newest first
+	if err := feed.ProcessBlock(feedBlock(2, "2'", "1", stakeLog(stakedEvent, c, feedStake))); err != nil {
+		t.Fatal(err)
+	}
+
+	changes := receive(t, sub, 3)
+	checkChange(t, changes[0], ValidatorAdded, a, true)
+	checkChange(t, changes[1], ValidatorRemoved, b, true)
+	checkChange(t, changes[2], ValidatorAdded, c, false)
+
+	if !feed.IsValidator(a) || feed.IsValidator(b) || !feed.IsValidator(c) {
+		t.Fatal("validator set doesn't follow the rollback")
+	}
+
+	expectNoChanges(t, sub)
+}
+
+func TestChangeFeedFailedBlockAfterRollback(t *testing.T) {
+	t.Parallel()
+
+	feed := newTestFeed(t, 0)
+	sub := feed.Subscribe()
+
+	a, b := types.StringToAddress("a"), types.StringToAddress("b")
+
+	if err := feed.ProcessBlock(feedBlock(1, "1", "", stakeLog(stakedEvent, a, feedStake))); err != nil {
+		t.Fatal(err)
+	}
+
+	receive(t, sub, 1)
+
+	// Block 1' rolls back block 1, then fails on a malformed log
+	malformed := stakeLog(stakedEvent, b, feedStake)
+	malformed.Data = malformed.Data[:8]
+
+	if err := feed.ProcessBlock(feedBlock(1, "1'", "", stakeLog(stakedEvent, b, feedStake), malformed)); err == nil {
+		t.Fatal("expected an error for the malformed log")
+	}
+
+	// The rollback happened, so its reversal is delivered, but nothing of the failed block
+	checkChange(t, receive(t, sub, 1)[0], ValidatorRemoved, a, true)
+	expectNoChanges(t, sub)
+
+	if feed.IsValidator(a) || feed.IsValidator(b) {
+		t.Fatal("failed block left changes in the validator set")
+	}
+
+	// The feed is back at the genesis block
+	if err := feed.ProcessBlock(feedBlock(1, "1''", "", stakeLog(stakedEvent, b, feedStake))); err != nil {
+		t.Fatal(err)
+	}
+
+	checkChange(t, receive(t, sub, 1)[0], ValidatorAdded, b, false)
+}
+
+func TestChangeFeedUnknownBlocks(t *testing.T) {
+	t.Parallel()
+
+	feed := newTestFeed(t, 2)
+	sub := feed.Subscribe()
+
+	if err := feed.ProcessBlock(feedBlock(5, "5", "4")); !errors.Is(err, ErrBlockNotLinked) {
+		t.Fatalf("expected %v, got %v", ErrBlockNotLinked, err)
+	}
+
+	for _, block := range []struct {
+		number       uint64
+		hash, parent string
+	}{
+		{1, "1", ""},
+		{2, "2", "1"},
+		{3, "3", "2"},
+	} {
+		if err := feed.ProcessBlock(feedBlock(block.number, block.hash, block.parent)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Only the last 2 blocks are kept, so a fork from genesis can't roll back block 1
+	if err := feed.ProcessBlock(feedBlock(1, "1'", "")); !errors.Is(err, ErrReorgTooDeep) {
+		t.Fatalf("expected %v, got %v", ErrReorgTooDeep, err)
+	}
+
+	expectNoChanges(t, sub)
+}
+
+func TestChangeFeedSubscriberQueriesFeed(t *testing.T) {
+	t.Parallel()
+
+	feed := newTestFeed(t, 0)
+	sub := feed.Subscribe()
+
+	// More changes than the subscription buffer holds
+	logs := make([]*types.Log, 0, 2*subscriptionBufferSize)
+	for idx := 0; idx < 2*subscriptionBufferSize; idx++ {
+		logs = append(logs, stakeLog(stakedEvent, types.BytesToAddress([]byte{1, byte(idx)}), feedStake))
+	}
+
+	handled := make(chan int)
+
+	go func() {
+		count := 0
+
+		for change := range sub.Changes() {
+			// Querying the feed while handling a change must not block the delivery
+			if !feed.IsValidator(change.Address) {
+				t.Errorf("%s is not a validator", change.Address)
+			}
+
+			if count++; count == len(logs) {
+				handled <- count
+
+				return
+			}
+		}
+	}()
+
+	done := make(chan error)
+
+	go func() {
+		done <- feed.ProcessBlock(feedBlock(1, "1", "", logs...))
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("block processing is blocked by the subscriber")
+	}
+
+	if count := <-handled; count != len(logs) {
+		t.Fatalf("handled %d changes, expected %d", count, len(logs))
+	}
+}
+
+func TestChangeFeedUnsubscribeWhileDelivering(t *testing.T) {
+	t.Parallel()
+
+	feed := newTestFeed(t, 0)
+	sub := feed.Subscribe()
+
+	logs := make([]*types.Log, 0, 2*subscriptionBufferSize)
+	for idx := 0; idx < 2*subscriptionBufferSize; idx++ {
+		logs = append(logs, stakeLog(stakedEvent, types.BytesToAddress([]byte{2, byte(idx)}), feedStake))
+	}
+
+	done := make(chan error)
+
+	go func() {
+		done <- feed.ProcessBlock(feedBlock(1, "1", "", logs...))
+	}()
+
+	// The buffer fills up, then unsubscribing releases the delivery
+	<-sub.Changes()
+	feed.Unsubscribe(sub)
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("unsubscribing doesn't release the delivery")
+	}
+}
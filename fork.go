@@ -0,0 +1,466 @@
+package staking
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/0xPolygon/polygon-edge/chain"
+	"github.com/0xPolygon/polygon-edge/helper/keccak"
+	"github.com/0xPolygon/polygon-edge/types"
+	"github.com/0xPolygon/polygon-edge/validators"
+)
+
+var (
+	ErrMissingPreimage     = errors.New("storage dump entry has no slot preimage")
+	ErrIncompleteDump      = errors.New("storage dump is incomplete, the last page has a next key")
+	ErrMissingNextKey      = errors.New("storage dump page before the last has no next key")
+	ErrDumpPageError       = errors.New("storage dump page is an error response")
+	ErrMissingDumpStorage  = errors.New("storage dump page has no storage")
+	ErrInvalidStorageWord  = errors.New("invalid storage word")
+	ErrMixedValidatorTypes = errors.New("some validators have a BLS public key and others don't")
+	ErrUnknownStakers      = errors.New("stakes of addresses that are neither validators nor candidates would be dropped")
+	ErrExtensionState      = errors.New("the extension state isn't carried over to the fork genesis")
+)
+
+// storageDumpEntry is a storage slot of a debug_storageRangeAt result,
+// or a slot and the eth_getStorageAt result for it
+type storageDumpEntry struct {
+	Key   *string `json:"key"`
+	Value string  `json:"value"`
+}
+
+// storageRangePage is a debug_storageRangeAt result, keyed by the hash of the slots
+type storageRangePage struct {
+	Storage map[string]storageDumpEntry `json:"storage"`
+	NextKey *string                     `json:"nextKey"`
+}
+
+// lastLayoutSlot is the last slot of the shared slot space of the staking contract versions
+var lastLayoutSlot = addressToPendingRewardSlot
+
+// extensionValueSlots are the slots of the extensions holding a value rather than a mapping
+var extensionValueSlots = []int64{
+	epochSizeSlot,
+	pendingChangesSlot,
+	pendingEpochSlot,
+	stakingTokenSlot,
+	missedBlocksThresholdSlot,
+	jailCooldownSlot,
+	adminSlot,
+	validatorThresholdSlot,
+}
+
+// extensionMapping is an address mapping of the extensions,
+// with the number of consecutive slots its values take
+type extensionMapping struct {
+	slot   int64
+	fields uint64
+}
+
+// extensionMappings are the address mappings of the extensions. The delegations,
+// a mapping of mappings, are only looked up for the delegation of a staker to itself
+var extensionMappings = []extensionMapping{
+	{addressToMetadataSlot, 3},
+	{addressToJailedUntilSlot, 1},
+	{addressToLockScheduleSlot, 4},
+	{addressToRewardPoolSlot, 4},
+	{addressToAutoCompoundSlot, 1},
+	{addressToPendingRewardSlot, 1},
+}
+
+// delegationFields is the number of consecutive slots a delegation takes
+const delegationFields = 3
+
+// ParseStorageRangeDump parses the storage of a contract dumped with debug_storageRangeAt.
+// The dump is the result object, the whole JSON-RPC response, or an array of them for
+// a paged dump, in which every page but the last has a next key.
+// Pages that are error responses or have no storage are rejected.
+//
+// Nodes often leave out the slot preimages, and key the storage by the hash of the slots only.
+// Those entries are matched against the slots of the known layout (see resolveHashedSlots),
+// and the ones that don't match are dropped, as the forked state isn't decoded from them
+func ParseStorageRangeDump(data []byte) (StorageMap, error) {
+	var pages []json.RawMessage
+
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '[' {
+		if err := json.Unmarshal(trimmed, &pages); err != nil {
+			return nil, fmt.Errorf("invalid storage range dump: %w", err)
+		}
+	} else {
+		pages = []json.RawMessage{trimmed}
+	}
+
+	storage := make(StorageMap)
+	hashed := make(StorageMap)
+
+	for idx, raw := range pages {
+		var page struct {
+			storageRangePage
+			Result *storageRangePage `json:"result"`
+			Error  json.RawMessage   `json:"error"`
+		}
+
+		if err := json.Unmarshal(raw, &page); err != nil {
+			return nil, fmt.Errorf("invalid storage range dump page %d: %w", idx, err)
+		}
+
+		if len(page.Error) > 0 && string(page.Error) != "null" {
+			return nil, fmt.Errorf("%w: page %d: %s", ErrDumpPageError, idx, page.Error)
+		}
+
+		result := &page.storageRangePage
+		if page.Result != nil {
+			result = page.Result
+		}
+
+		if result.Storage == nil {
+			return nil, fmt.Errorf("%w: page %d", ErrMissingDumpStorage, idx)
+		}
+
+		switch {
+		case idx == len(pages)-1 && result.NextKey != nil:
+			return nil, ErrIncompleteDump
+		case idx < len(pages)-1 && result.NextKey == nil:
+			return nil, fmt.Errorf("%w: page %d", ErrMissingNextKey, idx)
+		}
+
+		for hashedKey, entry := range result.Storage {
+			if entry.Key == nil {
+				if err := setDumpEntry(hashed, hashedKey, entry.Value); err != nil {
+					return nil, err
+				}
+
+				continue
+			}
+
+			if err := setDumpEntry(storage, *entry.Key, entry.Value); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if len(hashed) > 0 {
+		resolveHashedSlots(storage, hashed)
+	}
+
+	return storage, nil
+}
+
+// resolveHashedSlots moves the values keyed by the hash of their slot into the storage,
+// for the slots of the known layout. These are the fixed slots, the elements of the validators
+// and candidates arrays, the address mappings of those elements with the struct fields and
+// delegations of the extensions, and the chunks of their BLS public keys.
+// The array lengths and elements are read from the slots resolved before them
+func resolveHashedSlots(storage StorageMap, hashed StorageMap) {
+	resolve := func(slot types.Hash) {
+		key := types.BytesToHash(keccak.Keccak256(nil, slot.Bytes()))
+
+		if value, ok := hashed[key]; ok {
+			storage[slot] = value
+			delete(hashed, key)
+		}
+	}
+
+	for slot := int64(0); slot <= lastLayoutSlot; slot++ {
+		resolve(getSlotHash(slot))
+	}
+
+	stakers := make([]types.Address, 0)
+
+	for _, arraySlot := range []int64{validatorsSlot, candidatesSlot} {
+		length, ok := readUint64(storage, getSlotHash(arraySlot))
+		if !ok || length > maxArrayLength {
+			continue
+		}
+
+		for idx := uint64(0); idx < length; idx++ {
+			slot := getArrayElementIndex(arraySlot, idx)
+			resolve(slot)
+
+			if addr := readAddress(storage, slot); addr != types.ZeroAddress {
+				stakers = append(stakers, addr)
+			}
+		}
+	}
+
+	hasher := newSlotHasher()
+
+	for _, addr := range stakers {
+		for slot := int64(0); slot <= lastLayoutSlot; slot++ {
+			resolve(hasher.addressMapping(addr, getSlotHash(slot)))
+		}
+
+		for _, mapping := range extensionMappings {
+			base := hasher.addressMapping(addr, getSlotHash(mapping.slot))
+
+			for field := uint64(1); field < mapping.fields; field++ {
+				resolve(addOffset(base, field))
+			}
+		}
+
+		delegation := types.BytesToHash(getDelegationIndex(addr, addr))
+		for field := uint64(0); field < delegationFields; field++ {
+			resolve(addOffset(delegation, field))
+		}
+
+		// Long BLS public keys keep 2*length+1 in the mapping slot, and their data from its hash
+		base := hasher.addressMapping(addr, getSlotHash(addressToBLSPublicKeySlot))
+
+		lengthWord := readBig(storage, base)
+		if lengthWord.Bit(0) == 0 {
+			continue
+		}
+
+		length := new(big.Int).Rsh(lengthWord, 1)
+		if !length.IsUint64() || length.Uint64() > maxBytesLength {
+			continue
+		}
+
+		chunks := (length.Uint64() + types.HashLength - 1) / types.HashLength
+		dataIndex := types.BytesToHash(keccak.Keccak256(nil, base.Bytes()))
+
+		for offset := uint64(0); offset < chunks; offset++ {
+			resolve(addOffset(dataIndex, offset))
+		}
+	}
+}
+
+// ParseStorageAtDump parses the storage of a contract dumped with eth_getStorageAt,
+// as a JSON array of {"key": slot, "value": result} objects
+func ParseStorageAtDump(data []byte) (StorageMap, error) {
+	var entries []storageDumpEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("invalid storage dump: %w", err)
+	}
+
+	storage := make(StorageMap, len(entries))
+
+	for idx, entry := range entries {
+		if entry.Key == nil {
+			return nil, fmt.Errorf("storage dump entry %d: %w", idx, ErrMissingPreimage)
+		}
+
+		if err := setDumpEntry(storage, *entry.Key, entry.Value); err != nil {
+			return nil, err
+		}
+	}
+
+	return storage, nil
+}
+
+// setDumpEntry sets the slot and value of a storage dump entry, skipping empty slots
+func setDumpEntry(storage StorageMap, key, value string) error {
+	keyHash, err := parseStorageWord(key)
+	if err != nil {
+		return err
+	}
+
+	valueHash, err := parseStorageWord(value)
+	if err != nil {
+		return err
+	}
+
+	if valueHash != types.ZeroHash {
+		storage[keyHash] = valueHash
+	}
+
+	return nil
+}
+
+// parseStorageWord parses a hex storage slot or value of up to 32 bytes
+func parseStorageWord(word string) (types.Hash, error) {
+	digits := strings.TrimPrefix(word, "0x")
+
+	value, ok := new(big.Int).SetString(digits, 16)
+	if !ok || digits == "" || value.BitLen() > 256 {
+		return types.ZeroHash, fmt.Errorf("%w: %q", ErrInvalidStorageWord, word)
+	}
+
+	return types.BytesToHash(value.Bytes()), nil
+}
+
+// ForkedState is the state of a live staking contract, decoded from its storage
+// to be predeployed into the genesis of a fork
+type ForkedState struct {
+	Validators        []types.Address
+	Stakes            map[types.Address]*big.Int // Stakes of the validators
+	BLSPublicKeys     map[types.Address][]byte   // BLS public keys of the validators that registered one
+	Candidates        []GenesisCandidate         // Candidate pool of the ranked admission contract
+	MinValidatorCount uint64
+	MaxValidatorCount uint64
+	TotalStaked       *big.Int     // Total staked amount, including the stakers that aren't decoded
+	ExtensionSlots    []types.Hash // Slots holding extension state, which isn't decoded
+}
+
+// DecodeForkedState decodes the validators, their stakes and BLS public keys,
+// and the candidate pool from the staking contract storage.
+//
+// The stakes of other stakers and the extension state can't be carried over to the fork,
+// but are accounted for in the total staked amount and the extension slots.
+// The extension slots are the set value slots of the extensions, and the set slots
+// of their mappings for the validators and candidates
+func DecodeForkedState(r StorageReader) (*ForkedState, error) {
+	vals, err := UniqueValidators(r)
+	if err != nil {
+		return nil, err
+	}
+
+	candidates, err := readAddressArray(r, candidatesSlot)
+	if err != nil {
+		return nil, err
+	}
+
+	minValidators, ok := readUint64(r, getSlotHash(minNumValidatorSlot))
+	if !ok {
+		return nil, fmt.Errorf("invalid minimum validator count %s", readBig(r, getSlotHash(minNumValidatorSlot)))
+	}
+
+	maxValidators, ok := readUint64(r, getSlotHash(maxNumValidatorSlot))
+	if !ok {
+		return nil, fmt.Errorf("invalid maximum validator count %s", readBig(r, getSlotHash(maxNumValidatorSlot)))
+	}
+
+	s := &ForkedState{
+		Validators:        vals,
+		Stakes:            make(map[types.Address]*big.Int, len(vals)),
+		BLSPublicKeys:     make(map[types.Address][]byte),
+		Candidates:        make([]GenesisCandidate, 0, len(candidates)),
+		MinValidatorCount: minValidators,
+		MaxValidatorCount: maxValidators,
+		TotalStaked:       readBig(r, getSlotHash(stakedAmountSlot)),
+		ExtensionSlots:    extensionStateSlots(r, append(append([]types.Address{}, vals...), candidates...)),
+	}
+
+	for _, addr := range vals {
+		s.Stakes[addr] = readBig(r, types.BytesToHash(getAddressMapping(addr, addressToStakedAmountSlot)))
+
+		key, err := getBytesFromStorage(r, getAddressMapping(addr, addressToBLSPublicKeySlot))
+		if err != nil {
+			return nil, fmt.Errorf("BLS public key of %s: %w", addr, err)
+		}
+
+		if len(key) > 0 {
+			s.BLSPublicKeys[addr] = key
+		}
+	}
+
+	for _, addr := range candidates {
+		s.Candidates = append(s.Candidates, GenesisCandidate{
+			Address: addr,
+			Stake:   readBig(r, types.BytesToHash(getAddressMapping(addr, addressToStakedAmountSlot))),
+		})
+	}
+
+	return s, nil
+}
+
+// extensionStateSlots returns the set slots of the extensions that hold values,
+// and the set slots of the extension mappings for the stakers
+func extensionStateSlots(r StorageReader, stakers []types.Address) []types.Hash {
+	slots := make([]types.Hash, 0)
+	check := func(slot types.Hash) {
+		if r.GetStorage(slot) != types.ZeroHash {
+			slots = append(slots, slot)
+		}
+	}
+
+	for _, slot := range extensionValueSlots {
+		check(getSlotHash(slot))
+	}
+
+	hasher := newSlotHasher()
+
+	for _, addr := range stakers {
+		for _, mapping := range extensionMappings {
+			base := hasher.addressMapping(addr, getSlotHash(mapping.slot))
+
+			for field := uint64(0); field < mapping.fields; field++ {
+				check(addOffset(base, field))
+			}
+		}
+
+		delegation := types.BytesToHash(getDelegationIndex(addr, addr))
+		for field := uint64(0); field < delegationFields; field++ {
+			check(addOffset(delegation, field))
+		}
+	}
+
+	return slots
+}
+
+// ValidatorSet returns the validators as a BLS validator set if they all have a BLS public key,
+// or as an ECDSA validator set if none has
+func (s *ForkedState) ValidatorSet() (validators.Validators, error) {
+	if len(s.BLSPublicKeys) == 0 {
+		set := make([]*validators.ECDSAValidator, 0, len(s.Validators))
+		for _, addr := range s.Validators {
+			set = append(set, validators.NewECDSAValidator(addr))
+		}
+
+		return validators.NewECDSAValidatorSet(set...), nil
+	}
+
+	set := make([]*validators.BLSValidator, 0, len(s.Validators))
+
+	for _, addr := range s.Validators {
+		key, ok := s.BLSPublicKeys[addr]
+		if !ok {
+			return nil, fmt.Errorf("%w: %s has none", ErrMixedValidatorTypes, addr)
+		}
+
+		set = append(set, validators.NewBLSValidator(addr, key))
+	}
+
+	return validators.NewBLSValidatorSet(set...), nil
+}
+
+// Predeploy sets up the staking contract account of the fork genesis with the decoded state.
+// The validator counts, stakes and candidates of the params are replaced with the decoded ones,
+// the others, such as the bytecode of an extended contract, are kept.
+//
+// The state is rejected if it has stakes of other stakers or extension state,
+// rather than dropping them from the fork
+func (s *ForkedState) Predeploy(params PredeployParams) (*chain.GenesisAccount, error) {
+	if len(s.ExtensionSlots) > 0 {
+		return nil, fmt.Errorf(
+			"%w: %d slots are set, such as %s",
+			ErrExtensionState,
+			len(s.ExtensionSlots),
+			s.ExtensionSlots[0],
+		)
+	}
+
+	held := big.NewInt(0)
+	for _, stake := range s.Stakes {
+		held.Add(held, stake)
+	}
+
+	for _, candidate := range s.Candidates {
+		held.Add(held, candidate.Stake)
+	}
+
+	if s.TotalStaked == nil || held.Cmp(s.TotalStaked) != 0 {
+		return nil, fmt.Errorf(
+			"%w: the total staked amount is %s, the validators and candidates hold %s",
+			ErrUnknownStakers,
+			s.TotalStaked,
+			held,
+		)
+	}
+
+	vals, err := s.ValidatorSet()
+	if err != nil {
+		return nil, err
+	}
+
+	params.MinValidatorCount = s.MinValidatorCount
+	params.MaxValidatorCount = s.MaxValidatorCount
+	params.Stakes = s.Stakes
+	params.Candidates = s.Candidates
+
+	return PredeployStakingSC(vals, params)
+}
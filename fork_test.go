@@ -0,0 +1,287 @@
+package staking
+
+import (
+	"encoding/json"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/0xPolygon/polygon-edge/helper/keccak"
+	"github.com/0xPolygon/polygon-edge/types"
+)
+
+// storageRangeDump returns the storage as the pages of a debug_storageRangeAt dump,
+// keyed by the hash of the slots, with the preimages if withKeys is set
+func storageRangeDump(t *testing.T, storage map[types.Hash]types.Hash, pageSize int, withKeys bool) []byte {
+	t.Helper()
+
+	type entry struct {
+		Key   *types.Hash `json:"key"`
+		Value types.Hash  `json:"value"`
+	}
+
+	type page struct {
+		Storage map[types.Hash]entry `json:"storage"`
+		NextKey *types.Hash          `json:"nextKey"`
+	}
+
+	pages := []*page{{Storage: map[types.Hash]entry{}}}
+
+	for _, slot := range sortedStorageKeys(storage) {
+		last := pages[len(pages)-1]
+		if len(last.Storage) == pageSize {
+			next := types.BytesToHash(keccak.Keccak256(nil, slot.Bytes()))
+			last.NextKey = &next
+			last = &page{Storage: map[types.Hash]entry{}}
+			pages = append(pages, last)
+		}
+
+		slot := slot
+		e := entry{Value: storage[slot]}
+
+		if withKeys {
+			e.Key = &slot
+		}
+
+		last.Storage[types.BytesToHash(keccak.Keccak256(nil, slot.Bytes()))] = e
+	}
+
+	data, err := json.Marshal(pages)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return data
+}
+
+func TestParseStorageRangeDumpHashedKeys(t *testing.T) {
+	t.Parallel()
+
+	vals := testBLSValidators(5)
+	candidate := types.StringToAddress("c")
+
+	account, err := PredeployStakingSC(vals, PredeployParams{
+		MinValidatorCount: 1,
+		MaxValidatorCount: 10,
+		Bytecode:          testExtendedBytecode,
+		BytecodeHash:      testExtendedBytecodeHash,
+		Candidates:        []GenesisCandidate{{Address: candidate, Stake: big.NewInt(5)}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Dumps leave out the empty slots
+	expected := make(map[types.Hash]types.Hash)
+
+	for slot, value := range account.Storage {
+		if value != types.ZeroHash {
+			expected[slot] = value
+		}
+	}
+
+	for _, withKeys := range []bool{true, false} {
+		dump := storageRangeDump(t, account.Storage, 7, withKeys)
+
+		storage, err := ParseStorageRangeDump(dump)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Every slot of the genesis storage is part of the known layout
+		if len(storage) != len(expected) {
+			t.Fatalf("preimages %t: parsed %d slots, expected %d", withKeys, len(storage), len(expected))
+		}
+
+		for slot, value := range expected {
+			if storage[slot] != value {
+				t.Fatalf("preimages %t: slot %s is %s, expected %s", withKeys, slot, storage[slot], value)
+			}
+		}
+
+		state, err := DecodeForkedState(storage)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(state.Validators) != vals.Len() || len(state.BLSPublicKeys) != vals.Len() {
+			t.Fatalf("preimages %t: decoded %d validators with %d BLS public keys",
+				withKeys, len(state.Validators), len(state.BLSPublicKeys))
+		}
+
+		if len(state.Candidates) != 1 || state.Candidates[0].Address != candidate {
+			t.Fatalf("preimages %t: decoded candidates %v", withKeys, state.Candidates)
+		}
+	}
+}
+
+func TestParseStorageRangeDumpUnknownHashedKey(t *testing.T) {
+	t.Parallel()
+
+	dump := []byte(`{"storage": {
+		"0x0000000000000000000000000000000000000000000000000000000000000abc": {"key": null, "value": "0x1"}
+	}, "nextKey": null}`)
+
+	storage, err := ParseStorageRangeDump(dump)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(storage) != 0 {
+		t.Fatalf("unknown slot kept in %v", storage)
+	}
+}
+
+func TestParseStorageRangeDumpInvalidPages(t *testing.T) {
+	t.Parallel()
+
+	const (
+		entry   = `{"0x0000000000000000000000000000000000000000000000000000000000000abc": {"key": "0x1", "value": "0x1"}}`
+		nextKey = `"0x0000000000000000000000000000000000000000000000000000000000000abd"`
+	)
+
+	testTable := []struct {
+		name string
+		dump string
+		err  error
+	}{
+		{
+			name: "error response",
+			dump: `{"jsonrpc": "2.0", "id": 1, "error": {"code": -32000, "message": "missing trie node"}}`,
+			err:  ErrDumpPageError,
+		},
+		{
+			name: "page without storage",
+			dump: `{"jsonrpc": "2.0", "id": 1, "result": {"nextKey": null}}`,
+			err:  ErrMissingDumpStorage,
+		},
+		{
+			name: "page before the last without a next key",
+			dump: `[{"storage": ` + entry + `, "nextKey": null}, {"storage": {}, "nextKey": null}]`,
+			err:  ErrMissingNextKey,
+		},
+		{
+			name: "last page with a next key",
+			dump: `[{"storage": ` + entry + `, "nextKey": ` + nextKey + `}, {"storage": {}, "nextKey": ` + nextKey + `}]`,
+			err:  ErrIncompleteDump,
+		},
+	}
+
+	for _, test := range testTable {
+		test := test
+
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			if _, err := ParseStorageRangeDump([]byte(test.dump)); !errors.Is(err, test.err) {
+				t.Fatalf("expected %v, got %v", test.err, err)
+			}
+		})
+	}
+}
+
+func TestForkedStatePredeploy(t *testing.T) {
+	t.Parallel()
+
+	vals := testBLSValidators(3)
+	outsider := types.StringToAddress("5")
+
+	testTable := []struct {
+		name   string
+		params PredeployParams
+		// corrupt changes the genesis storage before it is forked
+		corrupt func(storage StorageMap)
+		err     error
+	}{
+		{
+			name: "validators and candidates",
+			params: PredeployParams{
+				Bytecode:     testExtendedBytecode,
+				BytecodeHash: testExtendedBytecodeHash,
+				Candidates:   []GenesisCandidate{{Address: outsider, Stake: big.NewInt(5)}},
+			},
+		},
+		{
+			name: "staker that is neither a validator nor a candidate",
+			corrupt: func(storage StorageMap) {
+				stakeTo(storage, outsider, 1)
+
+				total := readBig(storage, getSlotHash(stakedAmountSlot))
+				storage.SetStorage(getSlotHash(stakedAmountSlot), types.BytesToHash(total.Add(total, big.NewInt(1)).Bytes()))
+			},
+			err: ErrUnknownStakers,
+		},
+		{
+			name: "epoch transitions",
+			params: PredeployParams{
+				EpochSize:    10,
+				Bytecode:     testExtendedBytecode,
+				BytecodeHash: testExtendedBytecodeHash,
+			},
+			err: ErrExtensionState,
+		},
+		{
+			name: "validator commission only",
+			params: PredeployParams{
+				Metadata:     map[types.Address]*ValidatorMetadata{vals.At(1).Addr(): {Commission: 5}},
+				Bytecode:     testExtendedBytecode,
+				BytecodeHash: testExtendedBytecodeHash,
+			},
+			err: ErrExtensionState,
+		},
+	}
+
+	for _, test := range testTable {
+		test := test
+
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			params := test.params
+			params.MinValidatorCount = 1
+			params.MaxValidatorCount = 10
+
+			account, err := PredeployStakingSC(vals, params)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if test.corrupt != nil {
+				test.corrupt(account.Storage)
+			}
+
+			// Without the preimages, the extension state is found through the hashed slots only
+			storage, err := ParseStorageRangeDump(storageRangeDump(t, account.Storage, 10, false))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			state, err := DecodeForkedState(storage)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			forked, err := state.Predeploy(PredeployParams{
+				Bytecode:     params.Bytecode,
+				BytecodeHash: params.BytecodeHash,
+			})
+			if !errors.Is(err, test.err) {
+				t.Fatalf("expected %v, got %v", test.err, err)
+			}
+
+			if err != nil {
+				return
+			}
+
+			if len(forked.Storage) != len(account.Storage) {
+				t.Fatalf("%d slots in the fork genesis, expected %d", len(forked.Storage), len(account.Storage))
+			}
+
+			for slot, value := range account.Storage {
+				if forked.Storage[slot] != value {
+					t.Fatalf("slot %s is %s in the fork genesis, expected %s", slot, forked.Storage[slot], value)
+				}
+			}
+		})
+	}
+}
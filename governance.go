@@ -0,0 +1,209 @@
+package staking
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/0xPolygon/polygon-edge/types"
+	"github.com/umbracle/ethgo"
+	"github.com/umbracle/ethgo/abi"
+)
+
+// Slot definitions for the governance extension of the SC storage.
+// The admin can update the validator count limits and the stake threshold,
+// which are otherwise fixed at genesis
+var (
+	adminSlot              = int64(16) // Slot 16
+	validatorThresholdSlot = int64(17) // Slot 17
+)
+
+var (
+	ErrInvalidValidatorCount  = errors.New("invalid validator count limit")
+	ErrThresholdAboveStake    = errors.New("validator threshold is above the genesis stake")
+	ErrUnknownParameterChange = errors.New("log is not a parameter change event")
+	ErrInvalidParameterValue  = errors.New("invalid parameter value")
+
+	setMinimumNumValidatorsMethod = abi.MustNewMethod(
+		"function setMinimumNumValidators(uint256 minNumValidators)",
+	)
+	setMaximumNumValidatorsMethod = abi.MustNewMethod(
+		"function setMaximumNumValidators(uint256 maxNumValidators)",
+	)
+	setValidatorThresholdMethod = abi.MustNewMethod(
+		"function setValidatorThreshold(uint256 threshold)",
+	)
+
+	adminMethod                = abi.MustNewMethod("function admin() view returns (address admin)")
+	minimumNumValidatorsMethod = abi.MustNewMethod("function minimumNumValidators() view returns (uint256 value)")
+	maximumNumValidatorsMethod = abi.MustNewMethod("function maximumNumValidators() view returns (uint256 value)")
+	validatorThresholdMethod   = abi.MustNewMethod("function validatorThreshold() view returns (uint256 value)")
+
+	minimumNumValidatorsChangedEvent = abi.MustNewEvent(
+		"event MinimumNumValidatorsChanged(uint256 oldValue, uint256 newValue)",
+	)
+	maximumNumValidatorsChangedEvent = abi.MustNewEvent(
+		"event MaximumNumValidatorsChanged(uint256 oldValue, uint256 newValue)",
+	)
+	validatorThresholdChangedEvent = abi.MustNewEvent(
+		"event ValidatorThresholdChanged(uint256 oldValue, uint256 newValue)",
+	)
+)
+
+// Parameter is a staking parameter the admin can update
+type Parameter uint8
+
+const (
+	MinimumNumValidators Parameter = iota + 1
+	MaximumNumValidators
+	ValidatorThreshold
+)
+
+// String returns the name of the parameter
+func (p Parameter) String() string {
+	switch p {
+	case MinimumNumValidators:
+		return "minimumNumValidators"
+	case MaximumNumValidators:
+		return "maximumNumValidators"
+	case ValidatorThreshold:
+		return "validatorThreshold"
+	default:
+		return fmt.Sprintf("Parameter(%d)", uint8(p))
+	}
+}
+
+// GovernanceParams are the admin controlled parameters of the staking contract
+type GovernanceParams struct {
+	Admin                types.Address
+	MinimumNumValidators *big.Int
+	MaximumNumValidators *big.Int
+	ValidatorThreshold   *big.Int
+}
+
+// ParameterChange is a decoded parameter change event
+type ParameterChange struct {
+	Parameter Parameter
+	OldValue  *big.Int
+	NewValue  *big.Int
+}
+
+// setGovernanceStorage sets the admin and the validator threshold into the genesis storage
+func setGovernanceStorage(
+	w StorageWriter,
+	admin types.Address,
+	threshold *big.Int,
+) {
+	w.SetStorage(getSlotHash(adminSlot), types.BytesToHash(admin.Bytes()))
+	w.SetStorage(getSlotHash(validatorThresholdSlot), types.BytesToHash(threshold.Bytes()))
+}
+
+// GovernanceParams gets the admin controlled parameters from contract
+func (c *Client) GovernanceParams(from types.Address) (*GovernanceParams, error) {
+	outputs, err := c.callView(from, adminMethod)
+	if err != nil {
+		return nil, err
+	}
+
+	admin, ok := outputs["admin"].(ethgo.Address)
+	if !ok {
+		return nil, ErrFailedTypeAssertion
+	}
+
+	params := &GovernanceParams{
+		Admin: types.Address(admin),
+	}
+
+	for _, query := range []struct {
+		method *abi.Method
+		value  **big.Int
+	}{
+		{minimumNumValidatorsMethod, &params.MinimumNumValidators},
+		{maximumNumValidatorsMethod, &params.MaximumNumValidators},
+		{validatorThresholdMethod, &params.ValidatorThreshold},
+	} {
+		outputs, err := c.callView(from, query.method)
+		if err != nil {
+			return nil, err
+		}
+
+		if *query.value, err = decodeBig(outputs, "value"); err != nil {
+			return nil, err
+		}
+	}
+
+	return params, nil
+}
+
+// SetParameterTx builds the admin transaction updating the parameter to the given value
+func (c *Client) SetParameterTx(
+	from types.Address,
+	nonce uint64,
+	parameter Parameter,
+	value *big.Int,
+) (*types.Transaction, error) {
+	var method *abi.Method
+
+	switch parameter {
+	case MinimumNumValidators:
+		method = setMinimumNumValidatorsMethod
+	case MaximumNumValidators:
+		method = setMaximumNumValidatorsMethod
+	case ValidatorThreshold:
+		method = setValidatorThresholdMethod
+	default:
+		return nil, fmt.Errorf("unknown parameter %s", parameter)
+	}
+
+	if value == nil || value.Sign() < 0 {
+		return nil, fmt.Errorf("%w for %s", ErrInvalidParameterValue, parameter)
+	}
+
+	if parameter != ValidatorThreshold && value.Sign() == 0 {
+		return nil, ErrInvalidValidatorCount
+	}
+
+	return c.newStakingTx(from, nonce, big.NewInt(0), method, value)
+}
+
+// DecodeParameterChange decodes a parameter change event emitted by the staking contract,
+// failing for the events of any other contract
+func (c *Client) DecodeParameterChange(log *types.Log) (*ParameterChange, error) {
+	ethLog, err := c.contractLog(log)
+	if err != nil {
+		return nil, err
+	}
+
+	for parameter, event := range map[Parameter]*abi.Event{
+		MinimumNumValidators: minimumNumValidatorsChangedEvent,
+		MaximumNumValidators: maximumNumValidatorsChangedEvent,
+		ValidatorThreshold:   validatorThresholdChangedEvent,
+	} {
+		if !event.Match(ethLog) {
+			continue
+		}
+
+		values, err := event.ParseLog(ethLog)
+		if err != nil {
+			return nil, err
+		}
+
+		oldValue, err := decodeBig(values, "oldValue")
+		if err != nil {
+			return nil, err
+		}
+
+		newValue, err := decodeBig(values, "newValue")
+		if err != nil {
+			return nil, err
+		}
+
+		return &ParameterChange{
+			Parameter: parameter,
+			OldValue:  oldValue,
+			NewValue:  newValue,
+		}, nil
+	}
+
+	return nil, ErrUnknownParameterChange
+}
@@ -0,0 +1,63 @@
+package staking
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/0xPolygon/polygon-edge/chain"
+	"github.com/0xPolygon/polygon-edge/types"
+	"github.com/0xPolygon/polygon-edge/validators"
+)
+
+var (
+	ErrInvalidInstanceAddress   = errors.New("staking instance address must be set")
+	ErrDuplicateInstanceAddress = errors.New("duplicate staking instance address")
+)
+
+// StakingInstance is an independent staking contract predeployed at its own address,
+// such as separate validator and sequencer pools
+type StakingInstance struct {
+	Name       string                // Name of the instance, used in errors
+	Address    types.Address         // Address the contract is predeployed at
+	Validators validators.Validators // Pre-staked validators of the instance
+	Params     PredeployParams
+}
+
+// Client returns the client of the staking contract of the instance
+func (i *StakingInstance) Client(t TxQueryHandler) *Client {
+	return NewClient(t, i.Address)
+}
+
+// PredeployStakingInstances sets up the accounts of the staking contract instances,
+// returning the genesis accounts by their address
+func PredeployStakingInstances(
+	instances []StakingInstance,
+) (map[types.Address]*chain.GenesisAccount, error) {
+	accounts := make(map[types.Address]*chain.GenesisAccount, len(instances))
+
+	for idx := range instances {
+		instance := &instances[idx]
+
+		if instance.Address == types.ZeroAddress {
+			return nil, fmt.Errorf("staking instance %q: %w", instance.Name, ErrInvalidInstanceAddress)
+		}
+
+		if _, ok := accounts[instance.Address]; ok {
+			return nil, fmt.Errorf(
+				"staking instance %q at %s: %w",
+				instance.Name,
+				instance.Address,
+				ErrDuplicateInstanceAddress,
+			)
+		}
+
+		account, err := PredeployStakingSC(instance.Validators, instance.Params)
+		if err != nil {
+			return nil, fmt.Errorf("staking instance %q: %w", instance.Name, err)
+		}
+
+		accounts[instance.Address] = account
+	}
+
+	return accounts, nil
+}
@@ -0,0 +1,196 @@
+package staking
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/0xPolygon/polygon-edge/chain"
+	"github.com/0xPolygon/polygon-edge/types"
+)
+
+// ViolationKind is the staking contract invariant a violation breaks
+type ViolationKind uint8
+
+const (
+	// TotalStakeMismatch is a total staked amount that isn't the sum of the stakes
+	TotalStakeMismatch ViolationKind = iota + 1
+	// BalanceBelowStake is a contract balance that doesn't cover the total staked amount
+	BalanceBelowStake
+	// ValidatorCountOutOfRange is a validators array shorter than the minimum or longer than the maximum
+	ValidatorCountOutOfRange
+	// ValidatorNotMarked is an address in the validators array that isn't marked as a validator
+	ValidatorNotMarked
+	// ValidatorIndexMismatch is a validator index that isn't the position of the address in the array
+	ValidatorIndexMismatch
+	// DuplicateValidator is an address that appears in the validators array more than once
+	DuplicateValidator
+)
+
+// String returns the name of the violation kind
+func (k ViolationKind) String() string {
+	switch k {
+	case TotalStakeMismatch:
+		return "TotalStakeMismatch"
+	case BalanceBelowStake:
+		return "BalanceBelowStake"
+	case ValidatorCountOutOfRange:
+		return "ValidatorCountOutOfRange"
+	case ValidatorNotMarked:
+		return "ValidatorNotMarked"
+	case ValidatorIndexMismatch:
+		return "ValidatorIndexMismatch"
+	case DuplicateValidator:
+		return "DuplicateValidator"
+	default:
+		return fmt.Sprintf("ViolationKind(%d)", k)
+	}
+}
+
+// Violation is a broken invariant of the staking contract state
+type Violation struct {
+	Kind    ViolationKind
+	Address types.Address // Address the violation is about, the zero address for the whole state
+	Index   uint64        // Position in the validators array, for the violations about one of its entries
+	Message string
+}
+
+// String returns the description of the violation
+func (v Violation) String() string {
+	return fmt.Sprintf("%s: %s", v.Kind, v.Message)
+}
+
+// CheckStakingInvariants checks the invariants of the staking contract state:
+//
+//   - the total staked amount is the sum of the stakes
+//   - the balance covers the total staked amount
+//   - the number of validators is within the minimum and maximum
+//   - every validator is marked as one, at the index of its position in the array
+//   - no validator appears twice in the array
+//
+// The stakes of the validators and candidates are always summed. Storage mappings can't be
+// enumerated, so any other staked address must be passed in the stakers.
+// A nil balance skips the balance check, for contracts staking an ERC-20 token.
+// An error is only returned if the state can't be read
+func CheckStakingInvariants(
+	r StorageReader,
+	balance *big.Int,
+	stakers []types.Address,
+) ([]Violation, error) {
+	vals, err := readValidators(r)
+	if err != nil {
+		return nil, err
+	}
+
+	candidates, err := readAddressArray(r, candidatesSlot)
+	if err != nil {
+		return nil, err
+	}
+
+	violations := make([]Violation, 0)
+
+	// Stakes
+	totalStaked := readBig(r, getSlotHash(stakedAmountSlot))
+	sum := big.NewInt(0)
+	counted := make(map[types.Address]bool, len(vals)+len(candidates)+len(stakers))
+
+	for _, list := range [][]types.Address{vals, candidates, stakers} {
+		for _, addr := range list {
+			if counted[addr] {
+				continue
+			}
+
+			counted[addr] = true
+
+			sum.Add(sum, readBig(r, types.BytesToHash(getAddressMapping(addr, addressToStakedAmountSlot))))
+		}
+	}
+
+	if sum.Cmp(totalStaked) != 0 {
+		violations = append(violations, Violation{
+			Kind:    TotalStakeMismatch,
+			Message: fmt.Sprintf("total staked amount is %s, the stakes sum up to %s", totalStaked, sum),
+		})
+	}
+
+	if balance != nil && balance.Cmp(totalStaked) < 0 {
+		violations = append(violations, Violation{
+			Kind:    BalanceBelowStake,
+			Message: fmt.Sprintf("balance %s is below the total staked amount %s", balance, totalStaked),
+		})
+	}
+
+	// Validator count limits
+	minValidators := readBig(r, getSlotHash(minNumValidatorSlot))
+	maxValidators := readBig(r, getSlotHash(maxNumValidatorSlot))
+	count := new(big.Int).SetUint64(uint64(len(vals)))
+
+	if count.Cmp(minValidators) < 0 || count.Cmp(maxValidators) > 0 {
+		violations = append(violations, Violation{
+			Kind: ValidatorCountOutOfRange,
+			Message: fmt.Sprintf(
+				"%d validators, out of the range [%s, %s]",
+				len(vals),
+				minValidators,
+				maxValidators,
+			),
+		})
+	}
+
+	// Validators array entries
+	positions := make(map[types.Address]uint64, len(vals))
+
+	for idx, addr := range vals {
+		index := uint64(idx)
+
+		if first, ok := positions[addr]; ok {
+			violations = append(violations, Violation{
+				Kind:    DuplicateValidator,
+				Address: addr,
+				Index:   index,
+				Message: fmt.Sprintf("%s at index %d is already at index %d", addr, index, first),
+			})
+
+			continue
+		}
+
+		positions[addr] = index
+
+		if readBig(r, types.BytesToHash(getAddressMapping(addr, addressToIsValidatorSlot))).Cmp(big.NewInt(1)) != 0 {
+			violations = append(violations, Violation{
+				Kind:    ValidatorNotMarked,
+				Address: addr,
+				Index:   index,
+				Message: fmt.Sprintf("%s at index %d is not marked as a validator", addr, index),
+			})
+		}
+
+		validatorIndex := readBig(r, types.BytesToHash(getAddressMapping(addr, addressToValidatorIndexSlot)))
+		if !validatorIndex.IsUint64() || validatorIndex.Uint64() != index {
+			violations = append(violations, Violation{
+				Kind:    ValidatorIndexMismatch,
+				Address: addr,
+				Index:   index,
+				Message: fmt.Sprintf("%s at index %d has validator index %s", addr, index, validatorIndex),
+			})
+		}
+	}
+
+	return violations, nil
+}
+
+// CheckGenesisInvariants checks the invariants of the staking contract genesis account.
+// The balance check is skipped when the stake is held in an ERC-20 token
+func CheckGenesisInvariants(account *chain.GenesisAccount, stakers []types.Address) ([]Violation, error) {
+	storage := StorageMap(account.Storage)
+
+	balance := account.Balance
+	if balance == nil {
+		balance = big.NewInt(0)
+	}
+
+	if readAddress(storage, getSlotHash(stakingTokenSlot)) != types.ZeroAddress {
+		balance = nil
+	}
+
+	return CheckStakingInvariants(storage, balance, stakers)
+}
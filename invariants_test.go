@@ -0,0 +1,156 @@
+package staking
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/0xPolygon/polygon-edge/types"
+)
+
+func TestCheckGenesisInvariants(t *testing.T) {
+	t.Parallel()
+
+	vals := testBLSValidators(3)
+
+	account, err := PredeployStakingSC(vals, PredeployParams{
+		MinValidatorCount: 1,
+		MaxValidatorCount: 10,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	violations, err := CheckGenesisInvariants(account, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(violations) != 0 {
+		t.Fatalf("violations in the genesis account: %v", violations)
+	}
+}
+
+func TestCheckStakingInvariantsViolations(t *testing.T) {
+	t.Parallel()
+
+	staker := types.StringToAddress("5")
+
+	testTable := []struct {
+		name    string
+		corrupt func(storage StorageMap, vals []types.Address)
+		balance int64
+		stakers []types.Address
+		kind    ViolationKind
+	}{
+		{
+			name: "total staked amount above the stakes",
+			corrupt: func(storage StorageMap, vals []types.Address) {
+				storage.SetStorage(getSlotHash(stakedAmountSlot), addOffset(types.ZeroHash, 1))
+			},
+			kind: TotalStakeMismatch,
+		},
+		{
+			name: "stake of a staker missing from the total",
+			corrupt: func(storage StorageMap, vals []types.Address) {
+				stakeTo(storage, staker, 1)
+			},
+			stakers: []types.Address{staker},
+			kind:    TotalStakeMismatch,
+		},
+		{
+			name:    "balance below the total staked amount",
+			balance: 1,
+			kind:    BalanceBelowStake,
+		},
+		{
+			name: "fewer validators than the minimum",
+			corrupt: func(storage StorageMap, vals []types.Address) {
+				storage.SetStorage(getSlotHash(minNumValidatorSlot), addOffset(types.ZeroHash, 4))
+			},
+			kind: ValidatorCountOutOfRange,
+		},
+		{
+			name: "validator not marked",
+			corrupt: func(storage StorageMap, vals []types.Address) {
+				isValidator, _ := validatorMappings(vals[1])
+				storage.SetStorage(isValidator, types.ZeroHash)
+			},
+			kind: ValidatorNotMarked,
+		},
+		{
+			name: "wrong validator index",
+			corrupt: func(storage StorageMap, vals []types.Address) {
+				_, index := validatorMappings(vals[2])
+				storage.SetStorage(index, addOffset(types.ZeroHash, 1))
+			},
+			kind: ValidatorIndexMismatch,
+		},
+		{
+			name: "repeated validator",
+			corrupt: func(storage StorageMap, vals []types.Address) {
+				storage.SetStorage(getSlotHash(validatorsSlot), addOffset(types.ZeroHash, 4))
+				storage.SetStorage(getArrayElementIndex(validatorsSlot, 3), types.BytesToHash(vals[0].Bytes()))
+			},
+			kind: DuplicateValidator,
+		},
+	}
+
+	for _, test := range testTable {
+		test := test
+
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			storage, vals := testValidatorStorage(t, 3)
+			if test.corrupt != nil {
+				test.corrupt(storage, vals)
+			}
+
+			// The balance covers the stakes unless the test sets it
+			balance := new(big.Int).Lsh(big.NewInt(1), 128)
+			if test.balance != 0 {
+				balance = big.NewInt(test.balance)
+			}
+
+			violations, err := CheckStakingInvariants(storage, balance, test.stakers)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(violations) != 1 || violations[0].Kind != test.kind {
+				t.Fatalf("violations %v, expected one %s", violations, test.kind)
+			}
+		})
+	}
+}
+
+func TestCheckStakingInvariantsStakers(t *testing.T) {
+	t.Parallel()
+
+	storage, _ := testValidatorStorage(t, 2)
+	staker := types.StringToAddress("5")
+
+	// A staker outside the validators and candidates, counted in the total
+	totalStaked := readBig(storage, getSlotHash(stakedAmountSlot))
+	totalStaked.Add(totalStaked, big.NewInt(1))
+
+	storage.SetStorage(getSlotHash(stakedAmountSlot), types.BytesToHash(totalStaked.Bytes()))
+	stakeTo(storage, staker, 1)
+
+	violations, err := CheckStakingInvariants(storage, nil, []types.Address{staker, staker})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(violations) != 0 {
+		t.Fatalf("violations %v", violations)
+	}
+}
+
+// stakeTo sets the stake of the address without updating the total staked amount
+func stakeTo(storage StorageMap, addr types.Address, stake uint64) {
+	storage.SetStorage(
+		types.BytesToHash(getAddressMapping(addr, addressToStakedAmountSlot)),
+		addOffset(types.ZeroHash, stake),
+	)
+}
@@ -0,0 +1,132 @@
+package staking
+
+import (
+	"math/big"
+
+	"github.com/0xPolygon/polygon-edge/types"
+	"github.com/umbracle/ethgo/abi"
+)
+
+// Slot definitions for the jail extension of the SC storage.
+// A jailed validator is removed from the validators array but keeps its stake,
+// and can call unjail() to rejoin once the cooldown has passed
+var (
+	missedBlocksThresholdSlot = int64(13) // Slot 13
+	jailCooldownSlot          = int64(14) // Slot 14
+	addressToJailedUntilSlot  = int64(15) // Slot 15
+)
+
+var (
+	unjailMethod = abi.MustNewMethod("function unjail()")
+)
+
+// setJailStorage sets the jail parameters into the genesis storage
+func setJailStorage(
+	w StorageWriter,
+	missedBlocksThreshold uint64,
+	jailCooldown uint64,
+) {
+	w.SetStorage(
+		getSlotHash(missedBlocksThresholdSlot),
+		types.BytesToHash(new(big.Int).SetUint64(missedBlocksThreshold).Bytes()),
+	)
+
+	w.SetStorage(
+		getSlotHash(jailCooldownSlot),
+		types.BytesToHash(new(big.Int).SetUint64(jailCooldown).Bytes()),
+	)
+}
+
+// JailedUntil returns the block number until which the validator is jailed,
+// or 0 if it has never been jailed
+func JailedUntil(r StorageReader, validator types.Address) *big.Int {
+	return readBig(r, types.BytesToHash(getAddressMapping(validator, addressToJailedUntilSlot)))
+}
+
+// IsJailed returns true if the validator is still in its jail cooldown at the given block
+func IsJailed(r StorageReader, validator types.Address, blockNumber uint64) bool {
+	return JailedUntil(r, validator).Cmp(new(big.Int).SetUint64(blockNumber)) > 0
+}
+
+// UnjailTx builds the transaction that brings the jailed sender back into the validator set
+func (c *Client) UnjailTx(from types.Address, nonce uint64) (*types.Transaction, error) {
+	return c.newStakingTx(from, nonce, big.NewInt(0), unjailMethod)
+}
+
+// LivenessTracker counts the consecutive blocks each active validator failed to sign
+type LivenessTracker struct {
+	threshold uint64
+	missed    map[types.Address]uint64
+}
+
+// NewLivenessTracker creates a tracker reporting validators once they miss threshold blocks in a row
+func NewLivenessTracker(threshold uint64) *LivenessTracker {
+	return &LivenessTracker{
+		threshold: threshold,
+		missed:    make(map[types.Address]uint64),
+	}
+}
+
+// Missed returns the number of consecutive blocks the validator failed to sign
+func (t *LivenessTracker) Missed(validator types.Address) uint64 {
+	return t.missed[validator]
+}
+
+// Observe records the committed signers of a block sealed by the active validators,
+// and returns the validators that reached the missed blocks threshold with it
+func (t *LivenessTracker) Observe(active []types.Address, signers []types.Address) []types.Address {
+	signed := make(map[types.Address]bool, len(signers))
+	for _, addr := range signers {
+		signed[addr] = true
+	}
+
+	isActive := make(map[types.Address]bool, len(active))
+	reached := make([]types.Address, 0)
+
+	for _, addr := range active {
+		isActive[addr] = true
+
+		if signed[addr] {
+			delete(t.missed, addr)
+
+			continue
+		}
+
+		t.missed[addr]++
+
+		if t.missed[addr] == t.threshold {
+			reached = append(reached, addr)
+		}
+	}
+
+	// Validators that left the set start over if they rejoin
+	for addr := range t.missed {
+		if !isActive[addr] {
+			delete(t.missed, addr)
+		}
+	}
+
+	return reached
+}
+
+// CommittedSignersReader extracts the committed seals of sealed headers.
+// It is implemented by the consensus signer, which owns the header extra format
+type CommittedSignersReader interface {
+	// CommittedSigners returns the validators of the sealed header,
+	// and the ones among them whose committed seal is included
+	CommittedSigners(header *types.Header) (validators []types.Address, signers []types.Address, err error)
+}
+
+// ProcessHeader records the committed signers of the sealed header,
+// and returns the validators that reached the missed blocks threshold with it
+func (t *LivenessTracker) ProcessHeader(
+	reader CommittedSignersReader,
+	header *types.Header,
+) ([]types.Address, error) {
+	active, signers, err := reader.CommittedSigners(header)
+	if err != nil {
+		return nil, err
+	}
+
+	return t.Observe(active, signers), nil
+}
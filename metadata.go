@@ -0,0 +1,143 @@
+package staking
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/0xPolygon/polygon-edge/types"
+	"github.com/umbracle/ethgo"
+	"github.com/umbracle/ethgo/abi"
+)
+
+// Slot definitions for the metadata extension of the SC storage
+var (
+	addressToMetadataSlot = int64(11) // Slot 11
+)
+
+// MaxCommission is the commission rate of 100%, in basis points
+const MaxCommission = uint64(10000)
+
+var (
+	ErrInvalidCommission = errors.New("commission rate exceeds 100%")
+
+	setValidatorMetadataMethod = abi.MustNewMethod(
+		"function setValidatorMetadata(string moniker, string website, uint256 commission)",
+	)
+	validatorMetadataMethod = abi.MustNewMethod(
+		"function validatorMetadata(address validator) view " +
+			"returns (string moniker, string website, uint256 commission)",
+	)
+)
+
+// ValidatorMetadata is the display information a validator stores in the contract.
+//
+// It is stored in the mapping(address => ValidatorMetadata) as
+// struct { string moniker; string website; uint256 commission; }
+type ValidatorMetadata struct {
+	Moniker    string `json:"moniker"`
+	Website    string `json:"website"`
+	Commission uint64 `json:"commission"` // Commission rate in basis points
+}
+
+// Validate checks that the metadata can be set in the contract
+func (m *ValidatorMetadata) Validate() error {
+	if m.Commission > MaxCommission {
+		return ErrInvalidCommission
+	}
+
+	return nil
+}
+
+// setMetadataStorage sets the metadata of the validator into the genesis storage
+func setMetadataStorage(
+	w StorageWriter,
+	h *slotHasher,
+	address types.Address,
+	metadata *ValidatorMetadata,
+) {
+	// The struct fields occupy consecutive slots from the mapping index
+	baseIndex := h.addressMapping(address, getSlotHash(addressToMetadataSlot))
+
+	if metadata.Moniker != "" {
+		h.setBytesToStorage(w, baseIndex, []byte(metadata.Moniker))
+	}
+
+	if metadata.Website != "" {
+		h.setBytesToStorage(w, addOffset(baseIndex, 1), []byte(metadata.Website))
+	}
+
+	w.SetStorage(addOffset(baseIndex, 2), addOffset(types.ZeroHash, metadata.Commission))
+}
+
+// ValidatorMetadata gets the metadata of the validator from contract
+func (c *Client) ValidatorMetadata(from types.Address, validator types.Address) (*ValidatorMetadata, error) {
+	outputs, err := c.callView(from, validatorMetadataMethod, ethgo.Address(validator))
+	if err != nil {
+		return nil, err
+	}
+
+	moniker, err := decodeString(outputs, "moniker")
+	if err != nil {
+		return nil, err
+	}
+
+	website, err := decodeString(outputs, "website")
+	if err != nil {
+		return nil, err
+	}
+
+	commission, err := decodeBig(outputs, "commission")
+	if err != nil {
+		return nil, err
+	}
+
+	if !commission.IsUint64() {
+		return nil, ErrInvalidCommission
+	}
+
+	return &ValidatorMetadata{
+		Moniker:    moniker,
+		Website:    website,
+		Commission: commission.Uint64(),
+	}, nil
+}
+
+// SetValidatorMetadataTx builds the transaction setting the metadata of the sender
+func (c *Client) SetValidatorMetadataTx(
+	from types.Address,
+	nonce uint64,
+	metadata *ValidatorMetadata,
+) (*types.Transaction, error) {
+	if err := metadata.Validate(); err != nil {
+		return nil, err
+	}
+
+	return c.newStakingTx(
+		from,
+		nonce,
+		big.NewInt(0),
+		setValidatorMetadataMethod,
+		metadata.Moniker,
+		metadata.Website,
+		new(big.Int).SetUint64(metadata.Commission),
+	)
+}
+
+// validateGenesisMetadata checks that the genesis metadata only refers to genesis validators
+func validateGenesisMetadata(
+	metadata map[types.Address]*ValidatorMetadata,
+	genesisVals map[types.Address]bool,
+) error {
+	for addr, m := range metadata {
+		if !genesisVals[addr] {
+			return fmt.Errorf("metadata for %s, which is not a genesis validator", addr)
+		}
+
+		if err := m.Validate(); err != nil {
+			return fmt.Errorf("invalid metadata for %s: %w", addr, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,332 @@
+package staking
+
+import (
+	"bytes"
+	"errors"
+	"math/big"
+
+	"github.com/0xPolygon/polygon-edge/types"
+)
+
+// priorityWindowSizeFactor bounds the difference between the highest and the lowest
+// proposer priority to this many times the total stake
+const priorityWindowSizeFactor = 2
+
+const (
+	// MaxProposerRound is the highest round proposers are selected for. Each round takes
+	// one more step over all the validators, and rounds come from the peers
+	MaxProposerRound = uint64(1024)
+
+	// The committed priorities are kept every proposerCheckpointInterval heights,
+	// for the last proposerCheckpoints checkpoints
+	proposerCheckpointInterval = uint64(1024)
+	proposerCheckpoints        = 16
+)
+
+var (
+	ErrNoStakedValidators = errors.New("no validators with stake")
+	ErrGenesisHeight      = errors.New("the genesis block has no proposer")
+	ErrRoundTooHigh       = errors.New("round is above the highest proposer round")
+	ErrHeightPruned       = errors.New("height is before the kept proposer priorities")
+)
+
+// ProposerSelector selects block proposers by stake weighted round robin,
+// accumulating proposer priorities as Tendermint does.
+//
+// Every validator starts with zero priority. In each step every priority grows by the
+// validator stake, and the validator with the highest one, the lower address on ties,
+// is the proposer and has its priority reduced by the total stake.
+// Before each step the priorities are rescaled to stay within twice the total stake
+// of each other, and centered around zero.
+//
+// Each height starts from the priorities committed by the previous one, and its round r
+// applies r+1 steps to a copy of them. The priorities after the round the block was
+// committed in are the ones of the next height. A height is assumed to commit in round 0
+// unless Commit records another round for it, so the outcome only depends on
+// the validators, their stakes and the commit rounds.
+//
+// The committed priorities are checkpointed periodically, so going back replays
+// from the closest checkpoint rather than from genesis. Heights before the oldest
+// kept checkpoint, and their commit rounds, are pruned
+type ProposerSelector struct {
+	validators []types.Address
+	stakes     []*big.Int
+	total      *big.Int
+
+	priorities   []*big.Int            // Committed priorities at the start of the height
+	height       uint64                // Height the priorities are at
+	commitRounds map[uint64]uint64     // Rounds the heights were committed in, if not 0
+	checkpoints  map[uint64][]*big.Int // Committed priorities at the start of the heights
+	oldest       uint64                // Lowest height that isn't pruned
+}
+
+// NewProposerSelector creates a proposer selector over the validators array,
+// weighted by the stakes. Validators without stake are never selected
+func NewProposerSelector(
+	vals []types.Address,
+	stakes map[types.Address]*big.Int,
+) (*ProposerSelector, error) {
+	s := &ProposerSelector{
+		total:        big.NewInt(0),
+		commitRounds: make(map[uint64]uint64),
+	}
+
+	for _, addr := range vals {
+		stake, ok := stakes[addr]
+		if !ok || stake.Sign() <= 0 {
+			continue
+		}
+
+		s.validators = append(s.validators, addr)
+		s.stakes = append(s.stakes, new(big.Int).Set(stake))
+		s.total.Add(s.total, stake)
+	}
+
+	if len(s.validators) == 0 {
+		return nil, ErrNoStakedValidators
+	}
+
+	s.reset()
+
+	return s, nil
+}
+
+// NewProposerSelectorFromStorage creates a proposer selector over the validators array
+// and the stakes in the staking contract storage
+func NewProposerSelectorFromStorage(r StorageReader) (*ProposerSelector, error) {
+	vals, err := readValidators(r)
+	if err != nil {
+		return nil, err
+	}
+
+	stakes := make(map[types.Address]*big.Int, len(vals))
+	for _, addr := range vals {
+		stakes[addr] = readBig(r, types.BytesToHash(getAddressMapping(addr, addressToStakedAmountSlot)))
+	}
+
+	return NewProposerSelector(vals, stakes)
+}
+
+// Proposer returns the proposer of the block at the given height and round.
+// The first block after genesis is at height 1
+func (s *ProposerSelector) Proposer(height, round uint64) (types.Address, error) {
+	if height == 0 {
+		return types.ZeroAddress, ErrGenesisHeight
+	}
+
+	if round > MaxProposerRound {
+		return types.ZeroAddress, ErrRoundTooHigh
+	}
+
+	if height < s.oldest {
+		return types.ZeroAddress, ErrHeightPruned
+	}
+
+	s.advanceTo(height)
+
+	// Rounds don't move the committed priorities, they step a copy of them
+	priorities := copyPriorities(s.priorities)
+	proposer := 0
+
+	for step := uint64(0); step <= round; step++ {
+		proposer = s.increment(priorities)
+	}
+
+	return s.validators[proposer], nil
+}
+
+// Commit records the round the block at the given height was committed in,
+// which sets the priorities of the following heights
+func (s *ProposerSelector) Commit(height, round uint64) error {
+	if height == 0 {
+		return ErrGenesisHeight
+	}
+
+	if round > MaxProposerRound {
+		return ErrRoundTooHigh
+	}
+
+	if height < s.oldest {
+		return ErrHeightPruned
+	}
+
+	if s.commitRounds[height] == round {
+		return nil
+	}
+
+	if round == 0 {
+		delete(s.commitRounds, height)
+	} else {
+		s.commitRounds[height] = round
+	}
+
+	// The priorities past the height were computed with another commit round
+	for checkpoint := range s.checkpoints {
+		if checkpoint > height {
+			delete(s.checkpoints, checkpoint)
+		}
+	}
+
+	if s.height > height {
+		s.restore(height)
+	}
+
+	return nil
+}
+
+// advanceTo moves the committed priorities to the start of the height,
+// starting over from the closest checkpoint for earlier heights
+func (s *ProposerSelector) advanceTo(height uint64) {
+	if height < s.height {
+		s.restore(height)
+	}
+
+	for s.height < height {
+		for step := uint64(0); step <= s.commitRounds[s.height]; step++ {
+			s.increment(s.priorities)
+		}
+
+		s.height++
+
+		if s.height%proposerCheckpointInterval == 0 {
+			s.checkpoint()
+		}
+	}
+}
+
+// reset sets the priorities back to the ones of the first height
+func (s *ProposerSelector) reset() {
+	s.priorities = make([]*big.Int, len(s.validators))
+	for idx := range s.priorities {
+		s.priorities[idx] = big.NewInt(0)
+	}
+
+	s.height = 1
+	s.oldest = 1
+	s.checkpoints = map[uint64][]*big.Int{1: copyPriorities(s.priorities)}
+}
+
+// restore sets the priorities back to the closest checkpoint at or before the height,
+// which is never before the oldest kept height
+func (s *ProposerSelector) restore(height uint64) {
+	closest := s.oldest
+
+	for checkpoint := range s.checkpoints {
+		if checkpoint <= height && checkpoint > closest {
+			closest = checkpoint
+		}
+	}
+
+	s.priorities = copyPriorities(s.checkpoints[closest])
+	s.height = closest
+}
+
+// checkpoint keeps the priorities of the current height, pruning the heights
+// before the oldest checkpoint once there are more than proposerCheckpoints
+func (s *ProposerSelector) checkpoint() {
+	s.checkpoints[s.height] = copyPriorities(s.priorities)
+
+	if len(s.checkpoints) <= proposerCheckpoints {
+		return
+	}
+
+	delete(s.checkpoints, s.oldest)
+
+	s.oldest = s.height
+	for checkpoint := range s.checkpoints {
+		if checkpoint < s.oldest {
+			s.oldest = checkpoint
+		}
+	}
+
+	for height := range s.commitRounds {
+		if height < s.oldest {
+			delete(s.commitRounds, height)
+		}
+	}
+}
+
+// copyPriorities returns a deep copy of the priorities
+func copyPriorities(priorities []*big.Int) []*big.Int {
+	copied := make([]*big.Int, len(priorities))
+	for idx, priority := range priorities {
+		copied[idx] = new(big.Int).Set(priority)
+	}
+
+	return copied
+}
+
+// increment applies one step to the priorities and returns the index of its proposer
+func (s *ProposerSelector) increment(priorities []*big.Int) int {
+	s.rescale(priorities)
+	shiftByAverage(priorities)
+
+	proposer := 0
+
+	for idx, priority := range priorities {
+		priority.Add(priority, s.stakes[idx])
+
+		if idx == 0 {
+			continue
+		}
+
+		switch priority.Cmp(priorities[proposer]) {
+		case 1:
+			proposer = idx
+		case 0:
+			if bytes.Compare(s.validators[idx].Bytes(), s.validators[proposer].Bytes()) < 0 {
+				proposer = idx
+			}
+		}
+	}
+
+	priorities[proposer].Sub(priorities[proposer], s.total)
+
+	return proposer
+}
+
+// rescale divides the priorities by the smallest integer ratio that brings the
+// difference between the highest and the lowest one within the priority window
+func (s *ProposerSelector) rescale(priorities []*big.Int) {
+	lowest, highest := priorities[0], priorities[0]
+
+	for _, priority := range priorities[1:] {
+		if priority.Cmp(lowest) < 0 {
+			lowest = priority
+		}
+
+		if priority.Cmp(highest) > 0 {
+			highest = priority
+		}
+	}
+
+	diff := new(big.Int).Sub(highest, lowest)
+	window := new(big.Int).Mul(s.total, big.NewInt(priorityWindowSizeFactor))
+
+	if diff.Cmp(window) <= 0 {
+		return
+	}
+
+	// ratio = ceil(diff / window)
+	ratio := new(big.Int).Add(diff, window)
+	ratio.Sub(ratio, big.NewInt(1))
+	ratio.Quo(ratio, window)
+
+	for _, priority := range priorities {
+		priority.Quo(priority, ratio)
+	}
+}
+
+// shiftByAverage subtracts the floored average priority from every priority
+func shiftByAverage(priorities []*big.Int) {
+	sum := big.NewInt(0)
+	for _, priority := range priorities {
+		sum.Add(sum, priority)
+	}
+
+	average := sum.Div(sum, big.NewInt(int64(len(priorities))))
+
+	for _, priority := range priorities {
+		priority.Sub(priority, average)
+	}
+}
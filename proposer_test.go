@@ -0,0 +1,200 @@
+package staking
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/0xPolygon/polygon-edge/types"
+)
+
+var (
+	proposerA = types.StringToAddress("a")
+	proposerB = types.StringToAddress("b")
+	proposerC = types.StringToAddress("c")
+)
+
+// newTestProposerSelector creates a proposer selector over A, B and C staked 1, 2 and 3
+func newTestProposerSelector(t *testing.T) *ProposerSelector {
+	t.Helper()
+
+	s, err := NewProposerSelector(
+		[]types.Address{proposerA, proposerB, proposerC},
+		map[types.Address]*big.Int{
+			proposerA: big.NewInt(1),
+			proposerB: big.NewInt(2),
+			proposerC: big.NewInt(3),
+		},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return s
+}
+
+// proposerOf returns the proposer of the height and round, failing the test on errors
+func proposerOf(t *testing.T, s *ProposerSelector, height, round uint64) types.Address {
+	t.Helper()
+
+	proposer, err := s.Proposer(height, round)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return proposer
+}
+
+func TestProposerSelectorWeightedByStake(t *testing.T) {
+	t.Parallel()
+
+	s := newTestProposerSelector(t)
+	counts := make(map[types.Address]int)
+
+	// Every 6 heights, each validator proposes as many blocks as its stake
+	for height := uint64(1); height <= 60; height++ {
+		counts[proposerOf(t, s, height, 0)]++
+	}
+
+	for addr, expected := range map[types.Address]int{proposerA: 10, proposerB: 20, proposerC: 30} {
+		if counts[addr] != expected {
+			t.Fatalf("%s proposed %d blocks, expected %d", addr, counts[addr], expected)
+		}
+	}
+}
+
+func TestProposerSelectorQueryOrder(t *testing.T) {
+	t.Parallel()
+
+	forward := newTestProposerSelector(t)
+	expected := make(map[[2]uint64]types.Address)
+
+	for height := uint64(1); height <= 20; height++ {
+		for round := uint64(0); round < 4; round++ {
+			expected[[2]uint64{height, round}] = proposerOf(t, forward, height, round)
+		}
+	}
+
+	// Rounds don't move the committed priorities, so going back gives the same proposers
+	backward := newTestProposerSelector(t)
+
+	for height := uint64(20); height >= 1; height-- {
+		for round := uint64(3); ; round-- {
+			if proposer := proposerOf(t, backward, height, round); proposer != expected[[2]uint64{height, round}] {
+				t.Fatalf("height %d round %d: got %s, expected %s", height, round, proposer, expected[[2]uint64{height, round}])
+			}
+
+			if round == 0 {
+				break
+			}
+		}
+	}
+}
+
+func TestProposerSelectorCommitRound(t *testing.T) {
+	t.Parallel()
+
+	s := newTestProposerSelector(t)
+	reference := newTestProposerSelector(t)
+
+	for height := uint64(1); height <= 5; height++ {
+		proposerOf(t, s, height, 0)
+	}
+
+	// Height 5 committed in round 1 takes one more step than a round 0 commit
+	if err := s.Commit(5, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	for height := uint64(6); height <= 12; height++ {
+		if proposer, expected := proposerOf(t, s, height, 0), proposerOf(t, reference, height+1, 0); proposer != expected {
+			t.Fatalf("height %d: got %s, expected %s", height, proposer, expected)
+		}
+	}
+
+	// Setting the commit round back to 0 after the following heights were selected restores their proposers
+	if err := s.Commit(5, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	if proposer, expected := proposerOf(t, s, 6, 0), proposerOf(t, reference, 6, 0); proposer != expected {
+		t.Fatalf("got %s after reverting the commit round, expected %s", proposer, expected)
+	}
+
+	if err := s.Commit(0, 0); err != ErrGenesisHeight {
+		t.Fatalf("expected %v, got %v", ErrGenesisHeight, err)
+	}
+}
+
+func TestProposerSelectorRoundTooHigh(t *testing.T) {
+	t.Parallel()
+
+	s := newTestProposerSelector(t)
+
+	if _, err := s.Proposer(1, ^uint64(0)); err != ErrRoundTooHigh {
+		t.Fatalf("expected %v, got %v", ErrRoundTooHigh, err)
+	}
+
+	if err := s.Commit(1, MaxProposerRound+1); err != ErrRoundTooHigh {
+		t.Fatalf("expected %v, got %v", ErrRoundTooHigh, err)
+	}
+
+	proposerOf(t, s, 1, MaxProposerRound)
+}
+
+func TestProposerSelectorCheckpoints(t *testing.T) {
+	t.Parallel()
+
+	s := newTestProposerSelector(t)
+	reference := newTestProposerSelector(t)
+
+	// Commit rounds on both sides of a checkpoint, recorded before any selection on the reference
+	commits := map[uint64]uint64{proposerCheckpointInterval - 1: 2, 2*proposerCheckpointInterval + 5: 1}
+	for height, round := range commits {
+		if err := reference.Commit(height, round); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	last := 3 * proposerCheckpointInterval
+	proposerOf(t, s, last, 0)
+
+	// Committing before the later checkpoints drops them
+	for height, round := range commits {
+		if err := s.Commit(height, round); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, height := range []uint64{last, proposerCheckpointInterval + 1, 2, last - 1, proposerCheckpointInterval} {
+		if proposer, expected := proposerOf(t, s, height, 1), proposerOf(t, reference, height, 1); proposer != expected {
+			t.Fatalf("height %d: got %s, expected %s", height, proposer, expected)
+		}
+	}
+}
+
+func TestProposerSelectorPruning(t *testing.T) {
+	t.Parallel()
+
+	s := newTestProposerSelector(t)
+
+	if err := s.Commit(2, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	last := (proposerCheckpoints + 1) * proposerCheckpointInterval
+	proposerOf(t, s, last, 0)
+
+	if len(s.checkpoints) != proposerCheckpoints || len(s.commitRounds) != 0 {
+		t.Fatalf("kept %d checkpoints and %d commit rounds", len(s.checkpoints), len(s.commitRounds))
+	}
+
+	if _, err := s.Proposer(2, 0); err != ErrHeightPruned {
+		t.Fatalf("expected %v, got %v", ErrHeightPruned, err)
+	}
+
+	if err := s.Commit(2, 0); err != ErrHeightPruned {
+		t.Fatalf("expected %v, got %v", ErrHeightPruned, err)
+	}
+
+	proposerOf(t, s, 2*proposerCheckpointInterval, 0)
+}
@@ -0,0 +1,153 @@
+package staking
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/0xPolygon/polygon-edge/state/runtime"
+	"github.com/0xPolygon/polygon-edge/types"
+	"github.com/umbracle/ethgo"
+	"github.com/umbracle/ethgo/abi"
+)
+
+var (
+	// AddrStakingContract is the address the staking contract is predeployed at
+	AddrStakingContract = types.StringToAddress("1001")
+
+	// Gas limit used when calling view methods of the staking contract
+	queryGasLimit uint64 = 1000000
+
+	ErrFailedTypeAssertion = errors.New("failed type assertion")
+	ErrForeignLog          = errors.New("log was not emitted by the staking contract")
+)
+
+// TxQueryHandler is a interface to call view methods in the contract
+type TxQueryHandler interface {
+	Apply(*types.Transaction) (*runtime.ExecutionResult, error)
+	GetNonce(types.Address) uint64
+}
+
+// Client calls the view methods and builds the transactions of the staking contract
+// predeployed at the given address, so that several instances can be used side by side.
+// The contract of a single staking chain is at AddrStakingContract
+type Client struct {
+	handler TxQueryHandler
+	address types.Address
+}
+
+// NewClient creates a new client of the staking contract at the address.
+// The handler is only needed for queries, and can be nil when only building transactions
+func NewClient(t TxQueryHandler, address types.Address) *Client {
+	return &Client{
+		handler: t,
+		address: address,
+	}
+}
+
+// Address returns the address of the staking contract
+func (c *Client) Address() types.Address {
+	return c.address
+}
+
+// callView calls the view method of the staking contract and returns the decoded outputs
+func (c *Client) callView(
+	from types.Address,
+	method *abi.Method,
+	args ...interface{},
+) (map[string]interface{}, error) {
+	input, err := method.Encode(args)
+	if err != nil {
+		return nil, err
+	}
+
+	contractAddress := c.address
+	res, err := c.handler.Apply(&types.Transaction{
+		From:     from,
+		To:       &contractAddress,
+		Input:    input,
+		Nonce:    c.handler.GetNonce(from),
+		Gas:      queryGasLimit,
+		Value:    big.NewInt(0),
+		GasPrice: big.NewInt(0),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if res.Failed() {
+		return nil, res.Err
+	}
+
+	return method.Decode(res.ReturnValue)
+}
+
+// newStakingTx builds an unsigned transaction calling the method of the staking contract.
+// Gas and gas price are left for the caller to set
+func (c *Client) newStakingTx(
+	from types.Address,
+	nonce uint64,
+	value *big.Int,
+	method *abi.Method,
+	args ...interface{},
+) (*types.Transaction, error) {
+	input, err := method.Encode(args)
+	if err != nil {
+		return nil, err
+	}
+
+	contractAddress := c.address
+
+	return &types.Transaction{
+		From:     from,
+		To:       &contractAddress,
+		Input:    input,
+		Nonce:    nonce,
+		Value:    value,
+		GasPrice: big.NewInt(0),
+	}, nil
+}
+
+// decodeBig returns the uint256 output with the given name
+func decodeBig(outputs map[string]interface{}, name string) (*big.Int, error) {
+	value, ok := outputs[name].(*big.Int)
+	if !ok {
+		return nil, ErrFailedTypeAssertion
+	}
+
+	return value, nil
+}
+
+// decodeString returns the string output with the given name
+func decodeString(outputs map[string]interface{}, name string) (string, error) {
+	value, ok := outputs[name].(string)
+	if !ok {
+		return "", ErrFailedTypeAssertion
+	}
+
+	return value, nil
+}
+
+// toEthgoLog converts the log for decoding with the contract events
+func toEthgoLog(log *types.Log) *ethgo.Log {
+	topics := make([]ethgo.Hash, len(log.Topics))
+	for idx, topic := range log.Topics {
+		topics[idx] = ethgo.Hash(topic)
+	}
+
+	return &ethgo.Log{
+		Address: ethgo.Address(log.Address),
+		Topics:  topics,
+		Data:    log.Data,
+	}
+}
+
+// contractLog converts the log for decoding with the contract events,
+// checking that it was emitted by the staking contract of the client
+func (c *Client) contractLog(log *types.Log) (*ethgo.Log, error) {
+	if log.Address != c.address {
+		return nil, fmt.Errorf("%w %s: emitted by %s", ErrForeignLog, c.address, log.Address)
+	}
+
+	return toEthgoLog(log), nil
+}
@@ -0,0 +1,150 @@
+package staking
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/0xPolygon/polygon-edge/types"
+)
+
+var (
+	ErrDuplicateValidator = errors.New("duplicate validator")
+)
+
+// StorageWrite is a single storage slot update
+type StorageWrite struct {
+	Key   types.Hash
+	Value types.Hash
+}
+
+// UniqueValidators returns the validators array from the staking contract storage
+// without its repeated entries, keeping the first position of every address.
+// It is the usual source of truth for RepairValidatorIndexes.
+//
+// A corrupt array length is read past the end of the array, up to a bound,
+// so the zero address entries are left out too
+func UniqueValidators(r StorageReader) ([]types.Address, error) {
+	vals := readAddressArrayLenient(r, validatorsSlot)
+
+	seen := make(map[types.Address]bool, len(vals))
+	unique := make([]types.Address, 0, len(vals))
+
+	for _, addr := range vals {
+		if !seen[addr] && addr != types.ZeroAddress {
+			seen[addr] = true
+			unique = append(unique, addr)
+		}
+	}
+
+	return unique, nil
+}
+
+// RepairValidatorIndexes returns the storage writes that make the validators array,
+// and the isValidator and validatorIndex mappings, match the given validators.
+//
+// Addresses of the stored array that aren't in the validators are unmarked.
+// Storage mappings can't be enumerated, so any other address that may be wrongly
+// marked as a validator must be passed in the stale addresses.
+// A corrupt array length doesn't stop the repair: the first maxArrayLength elements
+// of the stored array are cleared past the new length.
+// Only the slots whose value differs are written, in a deterministic order
+func RepairValidatorIndexes(
+	r StorageReader,
+	vals []types.Address,
+	stale []types.Address,
+) ([]StorageWrite, error) {
+	stored := readAddressArrayLenient(r, validatorsSlot)
+
+	writes := make([]StorageWrite, 0)
+	set := func(key, value types.Hash) {
+		if r.GetStorage(key) != value {
+			writes = append(writes, StorageWrite{Key: key, Value: value})
+		}
+	}
+
+	positions := make(map[types.Address]uint64, len(vals))
+	for idx, addr := range vals {
+		if _, ok := positions[addr]; ok {
+			return nil, fmt.Errorf("%w: %s", ErrDuplicateValidator, addr)
+		}
+
+		positions[addr] = uint64(idx)
+	}
+
+	// The array length and elements, clearing the ones past the new length
+	set(getSlotHash(validatorsSlot), addOffset(types.ZeroHash, uint64(len(vals))))
+
+	validatorsBase := getArrayElementIndex(validatorsSlot, 0)
+
+	for idx, addr := range vals {
+		set(addOffset(validatorsBase, uint64(idx)), types.BytesToHash(addr.Bytes()))
+	}
+
+	for idx := len(vals); idx < len(stored); idx++ {
+		set(addOffset(validatorsBase, uint64(idx)), types.ZeroHash)
+	}
+
+	// The mappings of the validators
+	trueValue := types.BytesToHash([]byte{1})
+
+	for idx, addr := range vals {
+		set(types.BytesToHash(getAddressMapping(addr, addressToIsValidatorSlot)), trueValue)
+		set(types.BytesToHash(getAddressMapping(addr, addressToValidatorIndexSlot)), addOffset(types.ZeroHash, uint64(idx)))
+	}
+
+	// The mappings of the addresses that are no longer validators
+	cleared := make(map[types.Address]bool)
+
+	for _, list := range [][]types.Address{stored, stale} {
+		for _, addr := range list {
+			if _, ok := positions[addr]; ok || cleared[addr] {
+				continue
+			}
+
+			cleared[addr] = true
+
+			set(types.BytesToHash(getAddressMapping(addr, addressToIsValidatorSlot)), types.ZeroHash)
+			set(types.BytesToHash(getAddressMapping(addr, addressToValidatorIndexSlot)), types.ZeroHash)
+		}
+	}
+
+	return writes, nil
+}
+
+// StateOverride is a set of storage writes to a contract, applied at a fork block
+// in place of a migration transaction
+type StateOverride struct {
+	Block   uint64                    `json:"block"`
+	Address types.Address             `json:"address"`
+	Storage map[types.Hash]types.Hash `json:"storage"`
+}
+
+// NewStateOverride creates the override applying the writes to the contract at the fork block
+func NewStateOverride(block uint64, address types.Address, writes []StorageWrite) *StateOverride {
+	override := &StateOverride{
+		Block:   block,
+		Address: address,
+		Storage: make(map[types.Hash]types.Hash, len(writes)),
+	}
+
+	for _, write := range writes {
+		override.Storage[write.Key] = write.Value
+	}
+
+	return override
+}
+
+// Apply writes the override into the state if the block is the fork block,
+// returning true if it did
+func (o *StateOverride) Apply(state StateSetter, blockNumber uint64) bool {
+	if blockNumber != o.Block {
+		return false
+	}
+
+	w := NewStateWriter(state, o.Address)
+	for key, value := range o.Storage {
+		w.SetStorage(key, value)
+	}
+
+	return true
+}
@@ -0,0 +1,199 @@
+package staking
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/0xPolygon/polygon-edge/types"
+)
+
+// testValidatorStorage returns the genesis storage of the base contract with n validators
+func testValidatorStorage(t *testing.T, n int) (StorageMap, []types.Address) {
+	t.Helper()
+
+	vals := testBLSValidators(n)
+
+	account, err := PredeployStakingSC(vals, PredeployParams{
+		MinValidatorCount: 1,
+		MaxValidatorCount: 10,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	addresses := make([]types.Address, n)
+	for idx := range addresses {
+		addresses[idx] = vals.At(uint64(idx)).Addr()
+	}
+
+	return StorageMap(account.Storage), addresses
+}
+
+// applyWrites applies the storage writes to the storage
+func applyWrites(storage StorageMap, writes []StorageWrite) {
+	for _, write := range writes {
+		storage.SetStorage(write.Key, write.Value)
+	}
+}
+
+// validatorMappings returns the isValidator and validatorIndex mapping keys of the address
+func validatorMappings(addr types.Address) (types.Hash, types.Hash) {
+	return types.BytesToHash(getAddressMapping(addr, addressToIsValidatorSlot)),
+		types.BytesToHash(getAddressMapping(addr, addressToValidatorIndexSlot))
+}
+
+func TestRepairValidatorIndexesHealthyState(t *testing.T) {
+	t.Parallel()
+
+	storage, vals := testValidatorStorage(t, 4)
+
+	writes, err := RepairValidatorIndexes(storage, vals, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(writes) != 0 {
+		t.Fatalf("%d writes to a healthy state", len(writes))
+	}
+}
+
+func TestRepairValidatorIndexes(t *testing.T) {
+	t.Parallel()
+
+	outsider := types.StringToAddress("5")
+
+	testTable := []struct {
+		name    string
+		corrupt func(storage StorageMap, vals []types.Address)
+		stale   []types.Address
+		writes  int
+	}{
+		{
+			name: "repeated entry",
+			corrupt: func(storage StorageMap, vals []types.Address) {
+				storage.SetStorage(getSlotHash(validatorsSlot), addOffset(types.ZeroHash, 4))
+				storage.SetStorage(getArrayElementIndex(validatorsSlot, 3), types.BytesToHash(vals[0].Bytes()))
+			},
+			// The length and the repeated entry
+			writes: 2,
+		},
+		{
+			name: "wrong validator index",
+			corrupt: func(storage StorageMap, vals []types.Address) {
+				_, index := validatorMappings(vals[1])
+				storage.SetStorage(index, addOffset(types.ZeroHash, 7))
+			},
+			writes: 1,
+		},
+		{
+			name: "stale address marked as a validator",
+			corrupt: func(storage StorageMap, vals []types.Address) {
+				isValidator, index := validatorMappings(outsider)
+				storage.SetStorage(isValidator, types.BytesToHash([]byte{1}))
+				storage.SetStorage(index, addOffset(types.ZeroHash, 1))
+			},
+			stale:  []types.Address{outsider},
+			writes: 2,
+		},
+		{
+			name: "corrupt array length",
+			corrupt: func(storage StorageMap, vals []types.Address) {
+				storage.SetStorage(getSlotHash(validatorsSlot), types.Hash{0xff})
+			},
+			writes: 1,
+		},
+	}
+
+	for _, test := range testTable {
+		test := test
+
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			storage, vals := testValidatorStorage(t, 3)
+			test.corrupt(storage, vals)
+
+			unique, err := UniqueValidators(storage)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(unique) != len(vals) {
+				t.Fatalf("%d unique validators, expected %d", len(unique), len(vals))
+			}
+
+			writes, err := RepairValidatorIndexes(storage, unique, test.stale)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// Only the slots that were corrupted are written
+			if len(writes) != test.writes {
+				t.Fatalf("%d writes, expected %d", len(writes), test.writes)
+			}
+
+			applyWrites(storage, writes)
+
+			violations, err := CheckStakingInvariants(storage, nil, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(violations) != 0 {
+				t.Fatalf("violations after the repair: %v", violations)
+			}
+
+			for _, addr := range test.stale {
+				if isValidator, _ := validatorMappings(addr); storage.GetStorage(isValidator) != types.ZeroHash {
+					t.Fatalf("%s is still marked as a validator", addr)
+				}
+			}
+
+			if writes, err = RepairValidatorIndexes(storage, unique, test.stale); err != nil || len(writes) != 0 {
+				t.Fatalf("%d writes, %v after the repair", len(writes), err)
+			}
+		})
+	}
+}
+
+func TestRepairValidatorIndexesRemovedValidator(t *testing.T) {
+	t.Parallel()
+
+	storage, vals := testValidatorStorage(t, 3)
+
+	writes, err := RepairValidatorIndexes(storage, vals[1:], nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	applyWrites(storage, writes)
+
+	stored, err := readValidators(storage)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(stored) != 2 || stored[0] != vals[1] || stored[1] != vals[2] {
+		t.Fatalf("validators are %v", stored)
+	}
+
+	isValidator, index := validatorMappings(vals[0])
+	if storage.GetStorage(isValidator) != types.ZeroHash || storage.GetStorage(index) != types.ZeroHash {
+		t.Fatalf("%s is still marked as a validator", vals[0])
+	}
+
+	if storage.GetStorage(getArrayElementIndex(validatorsSlot, 2)) != types.ZeroHash {
+		t.Fatal("the element past the new length is not cleared")
+	}
+}
+
+func TestRepairValidatorIndexesDuplicateValidator(t *testing.T) {
+	t.Parallel()
+
+	storage, vals := testValidatorStorage(t, 2)
+
+	_, err := RepairValidatorIndexes(storage, []types.Address{vals[0], vals[0]}, nil)
+	if !errors.Is(err, ErrDuplicateValidator) {
+		t.Fatalf("expected %v, got %v", ErrDuplicateValidator, err)
+	}
+}
@@ -0,0 +1,276 @@
+package staking
+
+import (
+	"errors"
+	"math/big"
+
+	"github.com/0xPolygon/polygon-edge/helper/common"
+	"github.com/0xPolygon/polygon-edge/helper/keccak"
+	"github.com/0xPolygon/polygon-edge/types"
+)
+
+// Slot definitions for the delegator rewards extension of the SC storage
+var (
+	addressToRewardPoolSlot = int64(19) // Slot 19
+	delegationsSlot         = int64(20) // Slot 20
+)
+
+// RewardPrecision scales the cumulative reward per share
+var RewardPrecision = new(big.Int).Exp(big.NewInt(10), big.NewInt(18), nil)
+
+var (
+	ErrNegativeAmount          = errors.New("amount can't be negative")
+	ErrInsufficientDelegation  = errors.New("amount exceeds the delegation")
+	ErrInvalidRewardPoolRecord = errors.New("invalid reward pool record")
+)
+
+// Delegation is the stake of a delegator in a validator's reward pool.
+//
+// It is stored in the mapping(address validator => mapping(address delegator => Delegation)) as
+// struct { uint256 amount; uint256 rewardPerShareStart; uint256 pending; }
+type Delegation struct {
+	Amount              *big.Int // Delegated stake
+	RewardPerShareStart *big.Int // Cumulative reward per share when the rewards were last settled
+	Pending             *big.Int // Settled rewards that are not claimed yet
+}
+
+// RewardPool splits the rewards of a validator between its commission and its delegators,
+// proportionally to their stake. The validator's own stake is its delegation to itself.
+//
+// Following F1 fee distribution, the pool only tracks the cumulative reward per share,
+// and every delegation settles its rewards against it when the delegation changes.
+// It is stored in the mapping(address validator => RewardPool) as
+// struct { uint256 rewardPerShare; uint256 totalDelegated; uint256 commissionAccrued; uint256 dust; }
+type RewardPool struct {
+	Commission        uint64   // Commission rate in basis points
+	RewardPerShare    *big.Int // Cumulative reward per delegated unit, scaled by RewardPrecision
+	TotalDelegated    *big.Int // Sum of the delegated stake
+	CommissionAccrued *big.Int // Commission that is not claimed yet
+	Dust              *big.Int // Remainder of the scaled rewards not distributed yet
+
+	Delegations map[types.Address]*Delegation
+}
+
+// NewRewardPool creates an empty reward pool with the given commission rate
+func NewRewardPool(commission uint64) (*RewardPool, error) {
+	if commission > MaxCommission {
+		return nil, ErrInvalidCommission
+	}
+
+	return &RewardPool{
+		Commission:        commission,
+		RewardPerShare:    big.NewInt(0),
+		TotalDelegated:    big.NewInt(0),
+		CommissionAccrued: big.NewInt(0),
+		Dust:              big.NewInt(0),
+		Delegations:       make(map[types.Address]*Delegation),
+	}, nil
+}
+
+// Distribute splits the reward into the commission and the delegators' shares.
+//
+// The commission is rounded down. The scaled remainder of the delegators' part that can't be
+// spread evenly over the delegated stake is kept as dust and added to the next distribution,
+// so that no reward is lost and the claimable rewards never exceed the distributed ones
+func (p *RewardPool) Distribute(reward *big.Int) error {
+	if reward.Sign() < 0 {
+		return ErrNegativeAmount
+	}
+
+	commission := new(big.Int).Mul(reward, new(big.Int).SetUint64(p.Commission))
+	commission.Div(commission, new(big.Int).SetUint64(MaxCommission))
+
+	// Without delegators the whole reward goes to the validator
+	if p.TotalDelegated.Sign() == 0 {
+		p.CommissionAccrued.Add(p.CommissionAccrued, reward)
+
+		return nil
+	}
+
+	p.CommissionAccrued.Add(p.CommissionAccrued, commission)
+
+	scaled := new(big.Int).Sub(reward, commission)
+	scaled.Mul(scaled, RewardPrecision)
+	scaled.Add(scaled, p.Dust)
+
+	increment, dust := new(big.Int).QuoRem(scaled, p.TotalDelegated, new(big.Int))
+
+	p.RewardPerShare.Add(p.RewardPerShare, increment)
+	p.Dust = dust
+
+	return nil
+}
+
+// Delegate adds the amount to the delegation of the delegator
+func (p *RewardPool) Delegate(delegator types.Address, amount *big.Int) error {
+	if amount.Sign() < 0 {
+		return ErrNegativeAmount
+	}
+
+	delegation := p.settle(delegator)
+
+	delegation.Amount.Add(delegation.Amount, amount)
+	p.TotalDelegated.Add(p.TotalDelegated, amount)
+
+	return nil
+}
+
+// Undelegate removes the amount from the delegation of the delegator,
+// keeping its settled rewards claimable
+func (p *RewardPool) Undelegate(delegator types.Address, amount *big.Int) error {
+	if amount.Sign() < 0 {
+		return ErrNegativeAmount
+	}
+
+	delegation, ok := p.Delegations[delegator]
+	if !ok || delegation.Amount.Cmp(amount) < 0 {
+		return ErrInsufficientDelegation
+	}
+
+	p.settle(delegator)
+
+	delegation.Amount.Sub(delegation.Amount, amount)
+	p.TotalDelegated.Sub(p.TotalDelegated, amount)
+
+	return nil
+}
+
+// Rewards returns the rewards the delegator can claim, rounded down
+func (p *RewardPool) Rewards(delegator types.Address) *big.Int {
+	delegation, ok := p.Delegations[delegator]
+	if !ok {
+		return big.NewInt(0)
+	}
+
+	return new(big.Int).Add(delegation.Pending, p.unsettled(delegation))
+}
+
+// Claim returns the rewards of the delegator and resets them
+func (p *RewardPool) Claim(delegator types.Address) *big.Int {
+	if _, ok := p.Delegations[delegator]; !ok {
+		return big.NewInt(0)
+	}
+
+	delegation := p.settle(delegator)
+
+	rewards := delegation.Pending
+	delegation.Pending = big.NewInt(0)
+
+	return rewards
+}
+
+// ClaimCommission returns the accrued commission of the validator and resets it
+func (p *RewardPool) ClaimCommission() *big.Int {
+	commission := p.CommissionAccrued
+	p.CommissionAccrued = big.NewInt(0)
+
+	return commission
+}
+
+// unsettled returns the rewards of the delegation since it was last settled
+func (p *RewardPool) unsettled(delegation *Delegation) *big.Int {
+	rewards := new(big.Int).Sub(p.RewardPerShare, delegation.RewardPerShareStart)
+	rewards.Mul(rewards, delegation.Amount)
+
+	return rewards.Div(rewards, RewardPrecision)
+}
+
+// settle moves the unsettled rewards of the delegator into its pending rewards,
+// creating the delegation if it doesn't exist
+func (p *RewardPool) settle(delegator types.Address) *Delegation {
+	delegation, ok := p.Delegations[delegator]
+	if !ok {
+		delegation = &Delegation{
+			Amount:              big.NewInt(0),
+			RewardPerShareStart: new(big.Int).Set(p.RewardPerShare),
+			Pending:             big.NewInt(0),
+		}
+
+		p.Delegations[delegator] = delegation
+
+		return delegation
+	}
+
+	delegation.Pending.Add(delegation.Pending, p.unsettled(delegation))
+	delegation.RewardPerShareStart = new(big.Int).Set(p.RewardPerShare)
+
+	return delegation
+}
+
+// getDelegationIndex returns the storage index of the delegation of the delegator to the validator
+func getDelegationIndex(validator, delegator types.Address) []byte {
+	return keccak.Keccak256(
+		nil,
+		append(
+			common.PadLeftOrTrim(delegator.Bytes(), 32),
+			getAddressMapping(validator, delegationsSlot)...,
+		),
+	)
+}
+
+// setRewardsStorage sets the reward pool of the genesis validator into the genesis storage,
+// with its stake as its own delegation and all the reward indexes at zero
+func setRewardsStorage(
+	w StorageWriter,
+	h *slotHasher,
+	validator types.Address,
+	stake *big.Int,
+) {
+	poolIndex := h.addressMapping(validator, getSlotHash(addressToRewardPoolSlot))
+
+	// The delegation index is keccak(delegator . keccak(validator . slot)), the same as getDelegationIndex
+	delegationIndex := h.addressMapping(validator, h.addressMapping(validator, getSlotHash(delegationsSlot)))
+	stakeValue := types.BytesToHash(stake.Bytes())
+
+	// Only the non-zero fields of the structs need to be set:
+	// RewardPool.totalDelegated and Delegation.amount
+	w.SetStorage(addOffset(poolIndex, 1), stakeValue)
+	w.SetStorage(delegationIndex, stakeValue)
+}
+
+// ReadRewardPool reads the reward pool of the validator and the delegations of the given
+// delegators from the staking contract storage.
+// The commission rate is read from the validator metadata
+func ReadRewardPool(
+	r StorageReader,
+	validator types.Address,
+	delegators []types.Address,
+) (*RewardPool, error) {
+	commission, ok := readUint64(
+		r,
+		addOffset(types.BytesToHash(getAddressMapping(validator, addressToMetadataSlot)), 2),
+	)
+	if !ok {
+		return nil, ErrInvalidCommission
+	}
+
+	pool, err := NewRewardPool(commission)
+	if err != nil {
+		return nil, err
+	}
+
+	poolIndex := getAddressMapping(validator, addressToRewardPoolSlot)
+
+	pool.RewardPerShare = readBig(r, addOffset(types.BytesToHash(poolIndex), 0))
+	pool.TotalDelegated = readBig(r, addOffset(types.BytesToHash(poolIndex), 1))
+	pool.CommissionAccrued = readBig(r, addOffset(types.BytesToHash(poolIndex), 2))
+	pool.Dust = readBig(r, addOffset(types.BytesToHash(poolIndex), 3))
+
+	for _, delegator := range delegators {
+		delegationIndex := getDelegationIndex(validator, delegator)
+
+		delegation := &Delegation{
+			Amount:              readBig(r, addOffset(types.BytesToHash(delegationIndex), 0)),
+			RewardPerShareStart: readBig(r, addOffset(types.BytesToHash(delegationIndex), 1)),
+			Pending:             readBig(r, addOffset(types.BytesToHash(delegationIndex), 2)),
+		}
+
+		if delegation.RewardPerShareStart.Cmp(pool.RewardPerShare) > 0 {
+			return nil, ErrInvalidRewardPoolRecord
+		}
+
+		pool.Delegations[delegator] = delegation
+	}
+
+	return pool, nil
+}
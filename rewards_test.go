@@ -0,0 +1,302 @@
+package staking
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/0xPolygon/polygon-edge/types"
+)
+
+// rewardOp is an operation on a reward pool: a distribution, or a delegation change
+type rewardOp struct {
+	distribute int64         // Reward to distribute, if no delegator is set
+	delegator  types.Address // Delegator to delegate to, or undelegate from
+	amount     int64         // Amount to delegate, or to undelegate if negative
+}
+
+var (
+	delegatorA = types.StringToAddress("a")
+	delegatorB = types.StringToAddress("b")
+	delegatorC = types.StringToAddress("c")
+)
+
+// checkRewardSplit checks that the claimable rewards, the commission and the scaled dust
+// never exceed the distributed total, and that at most one wei per delegator and
+// settlement is lost to rounding
+func checkRewardSplit(t *testing.T, pool *RewardPool, distributed, claimed *big.Int, settlements int) {
+	t.Helper()
+
+	paid := new(big.Int).Add(claimed, pool.CommissionAccrued)
+	for delegator := range pool.Delegations {
+		paid.Add(paid, pool.Rewards(delegator))
+	}
+
+	// (paid * precision + dust) <= distributed * precision
+	scaledPaid := new(big.Int).Mul(paid, RewardPrecision)
+	scaledPaid.Add(scaledPaid, pool.Dust)
+
+	if scaledPaid.Cmp(new(big.Int).Mul(distributed, RewardPrecision)) > 0 {
+		t.Fatalf("paid %s with dust %s, more than the distributed %s", paid, pool.Dust, distributed)
+	}
+
+	lost := new(big.Int).Sub(distributed, paid)
+	if lost.Cmp(big.NewInt(int64(settlements+len(pool.Delegations)))) > 0 {
+		t.Fatalf("%s lost to rounding, over %d settlements", lost, settlements)
+	}
+}
+
+func TestRewardPoolRounding(t *testing.T) {
+	t.Parallel()
+
+	testTable := []struct {
+		name       string
+		commission uint64
+		ops        []rewardOp
+		// Expected rewards of the delegators and accrued commission after the ops
+		rewards           map[types.Address]int64
+		commissionAccrued int64
+	}{
+		{
+			name:       "no commission splits by stake",
+			commission: 0,
+			ops: []rewardOp{
+				{delegator: delegatorA, amount: 1},
+				{delegator: delegatorB, amount: 3},
+				{distribute: 400},
+			},
+			rewards:           map[types.Address]int64{delegatorA: 100, delegatorB: 300},
+			commissionAccrued: 0,
+		},
+		{
+			name:       "one basis point rounds the commission down",
+			commission: 1,
+			ops: []rewardOp{
+				{delegator: delegatorA, amount: 1},
+				{distribute: 9999},
+			},
+			rewards:           map[types.Address]int64{delegatorA: 9999},
+			commissionAccrued: 0,
+		},
+		{
+			name:       "one basis point of a large reward",
+			commission: 1,
+			ops: []rewardOp{
+				{delegator: delegatorA, amount: 1},
+				{distribute: 20000},
+			},
+			rewards:           map[types.Address]int64{delegatorA: 19998},
+			commissionAccrued: 2,
+		},
+		{
+			name:       "full commission leaves nothing to the delegators",
+			commission: MaxCommission,
+			ops: []rewardOp{
+				{delegator: delegatorA, amount: 5},
+				{distribute: 1000},
+				{distribute: 1},
+			},
+			rewards:           map[types.Address]int64{delegatorA: 0},
+			commissionAccrued: 1001,
+		},
+		{
+			name:       "no delegators gives the validator the whole reward",
+			commission: 500,
+			ops: []rewardOp{
+				{distribute: 77},
+			},
+			rewards:           map[types.Address]int64{},
+			commissionAccrued: 77,
+		},
+		{
+			name:       "one wei reward is kept as dust",
+			commission: 0,
+			ops: []rewardOp{
+				{delegator: delegatorA, amount: 1},
+				{delegator: delegatorB, amount: 1},
+				{distribute: 1},
+			},
+			rewards:           map[types.Address]int64{delegatorA: 0, delegatorB: 0},
+			commissionAccrued: 0,
+		},
+		{
+			name:       "dust is carried across distributions",
+			commission: 0,
+			ops: []rewardOp{
+				{delegator: delegatorA, amount: 1},
+				{delegator: delegatorB, amount: 1},
+				{delegator: delegatorC, amount: 1},
+				{distribute: 1},
+				{distribute: 1},
+				{distribute: 1},
+			},
+			rewards:           map[types.Address]int64{delegatorA: 1, delegatorB: 1, delegatorC: 1},
+			commissionAccrued: 0,
+		},
+		{
+			name:       "delegation between distributions only earns the later ones",
+			commission: 0,
+			ops: []rewardOp{
+				{delegator: delegatorA, amount: 1},
+				{distribute: 100},
+				{delegator: delegatorB, amount: 1},
+				{distribute: 100},
+			},
+			rewards:           map[types.Address]int64{delegatorA: 150, delegatorB: 50},
+			commissionAccrued: 0,
+		},
+		{
+			name:       "undelegation between distributions keeps the settled rewards",
+			commission: 1000,
+			ops: []rewardOp{
+				{delegator: delegatorA, amount: 1},
+				{delegator: delegatorB, amount: 1},
+				{distribute: 100},
+				{delegator: delegatorB, amount: -1},
+				{distribute: 100},
+			},
+			rewards:           map[types.Address]int64{delegatorA: 135, delegatorB: 45},
+			commissionAccrued: 20,
+		},
+	}
+
+	for _, test := range testTable {
+		test := test
+
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			pool, err := NewRewardPool(test.commission)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			distributed := big.NewInt(0)
+			settlements := 0
+
+			for _, op := range test.ops {
+				switch {
+				case op.delegator == types.ZeroAddress:
+					err = pool.Distribute(big.NewInt(op.distribute))
+					distributed.Add(distributed, big.NewInt(op.distribute))
+				case op.amount >= 0:
+					err = pool.Delegate(op.delegator, big.NewInt(op.amount))
+					settlements++
+				default:
+					err = pool.Undelegate(op.delegator, big.NewInt(-op.amount))
+					settlements++
+				}
+
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				checkRewardSplit(t, pool, distributed, big.NewInt(0), settlements)
+			}
+
+			for delegator, expected := range test.rewards {
+				if rewards := pool.Rewards(delegator); rewards.Cmp(big.NewInt(expected)) != 0 {
+					t.Fatalf("rewards of %s are %s, expected %d", delegator, rewards, expected)
+				}
+			}
+
+			if pool.CommissionAccrued.Cmp(big.NewInt(test.commissionAccrued)) != 0 {
+				t.Fatalf("commission is %s, expected %d", pool.CommissionAccrued, test.commissionAccrued)
+			}
+
+			// Claiming pays the same rewards, and leaves nothing to claim
+			claimed := big.NewInt(0)
+
+			for delegator, expected := range test.rewards {
+				if rewards := pool.Claim(delegator); rewards.Cmp(big.NewInt(expected)) != 0 {
+					t.Fatalf("claimed %s for %s, expected %d", rewards, delegator, expected)
+				}
+
+				claimed.Add(claimed, big.NewInt(expected))
+				settlements++
+			}
+
+			for delegator := range test.rewards {
+				if rewards := pool.Rewards(delegator); rewards.Sign() != 0 {
+					t.Fatalf("%s left to claim for %s", rewards, delegator)
+				}
+			}
+
+			checkRewardSplit(t, pool, distributed, claimed, settlements)
+		})
+	}
+}
+
+func TestRewardPoolRoundingSweep(t *testing.T) {
+	t.Parallel()
+
+	// Awkward stakes and rewards, so that almost every distribution leaves dust
+	stakes := []int64{1, 3, 7}
+	rewards := []int64{1, 2, 5, 10, 333, 1000, 99999}
+
+	for _, commission := range []uint64{0, 1, 2500, 9999, MaxCommission} {
+		pool, err := NewRewardPool(commission)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		delegators := []types.Address{delegatorA, delegatorB, delegatorC}
+		distributed := big.NewInt(0)
+		claimed := big.NewInt(0)
+		settlements := 0
+
+		for round, reward := range rewards {
+			delegator := delegators[round%len(delegators)]
+
+			// Change a delegation between every distribution
+			if round%2 == 0 {
+				if err := pool.Delegate(delegator, big.NewInt(stakes[round%len(stakes)])); err != nil {
+					t.Fatal(err)
+				}
+			} else if delegation, ok := pool.Delegations[delegator]; ok && delegation.Amount.Sign() > 0 {
+				if err := pool.Undelegate(delegator, big.NewInt(1)); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			settlements++
+
+			if err := pool.Distribute(big.NewInt(reward)); err != nil {
+				t.Fatal(err)
+			}
+
+			distributed.Add(distributed, big.NewInt(reward))
+
+			if round%3 == 0 {
+				claimed.Add(claimed, pool.Claim(delegator))
+				settlements++
+			}
+
+			checkRewardSplit(t, pool, distributed, claimed, settlements)
+		}
+	}
+}
+
+func TestRewardPoolInvalidAmounts(t *testing.T) {
+	t.Parallel()
+
+	if _, err := NewRewardPool(MaxCommission + 1); err != ErrInvalidCommission {
+		t.Fatalf("expected %v, got %v", ErrInvalidCommission, err)
+	}
+
+	pool, err := NewRewardPool(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := pool.Distribute(big.NewInt(-1)); err != ErrNegativeAmount {
+		t.Fatalf("expected %v, got %v", ErrNegativeAmount, err)
+	}
+
+	if err := pool.Delegate(delegatorA, big.NewInt(-1)); err != ErrNegativeAmount {
+		t.Fatalf("expected %v, got %v", ErrNegativeAmount, err)
+	}
+
+	if err := pool.Undelegate(delegatorA, big.NewInt(1)); err != ErrInsufficientDelegation {
+		t.Fatalf("expected %v, got %v", ErrInsufficientDelegation, err)
+	}
+}
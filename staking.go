@@ -1,12 +1,12 @@
 package staking
 
 import (
+	"errors"
 	"fmt"
 	"math/big"
 
 	"github.com/0xPolygon/polygon-edge/chain"
 	"github.com/0xPolygon/polygon-edge/helper/common"
-	"github.com/0xPolygon/polygon-edge/helper/hex"
 	"github.com/0xPolygon/polygon-edge/helper/keccak"
 	"github.com/0xPolygon/polygon-edge/types"
 	"github.com/0xPolygon/polygon-edge/validators"
@@ -17,6 +17,17 @@ var (
 	MaxValidatorCount = common.MaxSafeJSInt
 )
 
+var (
+	ErrExtensionBytecodeRequired = errors.New(
+		"contract extensions require the bytecode of the extended staking contract",
+	)
+	ErrExtensionBytecodeHashRequired = errors.New(
+		"contract extensions require the code hash the extended staking contract is pinned to",
+	)
+	ErrBaseContractExtensions = errors.New("the contract in StakingSCBytecode implements no extensions")
+	ErrInvalidGenesisStake    = errors.New("invalid genesis stake")
+)
+
 // getAddressMapping returns the key for the SC storage mapping (address => something)
 //
 // More information:
@@ -32,106 +43,115 @@ func getAddressMapping(address types.Address, slot int64) []byte {
 	return keccak.Keccak256(nil, finalSlice)
 }
 
-// getIndexWithOffset is a helper method for adding an offset to the already found keccak hash
-func getIndexWithOffset(keccakHash []byte, offset uint64) []byte {
-	bigOffset := big.NewInt(int64(offset))
-	bigKeccak := big.NewInt(0).SetBytes(keccakHash)
+// genesisIndexer computes the storage indexes of the genesis validators,
+// hashing the slots and the validators array base only once
+type genesisIndexer struct {
+	hasher *slotHasher
+
+	validatorsBase     types.Hash
+	isValidatorSlot    types.Hash
+	stakedAmountSlot   types.Hash
+	validatorIndexSlot types.Hash
+	blsPublicKeySlot   types.Hash
+
+	// The indexes of the last getStorageIndexes call, which storageIndexes points into
+	indexes        [5]types.Hash
+	storageIndexes StorageIndexes
+}
+
+// newGenesisIndexer creates a new genesis indexer
+func newGenesisIndexer() *genesisIndexer {
+	hasher := newSlotHasher()
+	g := &genesisIndexer{
+		hasher: hasher,
+		// The slot for the dynamic arrays that's put in the keccak needs to be in hex form (padded 64 chars)
+		validatorsBase:     hasher.dataIndex(getSlotHash(validatorsSlot)),
+		isValidatorSlot:    getSlotHash(addressToIsValidatorSlot),
+		stakedAmountSlot:   getSlotHash(addressToStakedAmountSlot),
+		validatorIndexSlot: getSlotHash(addressToValidatorIndexSlot),
+		blsPublicKeySlot:   getSlotHash(addressToBLSPublicKeySlot),
+	}
 
-	bigKeccak.Add(bigKeccak, bigOffset)
+	g.storageIndexes = StorageIndexes{
+		ValidatorsIndex:              g.indexes[0][:],
+		ValidatorBLSPublicKeyIndex:   g.indexes[1][:],
+		AddressToIsValidatorIndex:    g.indexes[2][:],
+		AddressToStakedAmountIndex:   g.indexes[3][:],
+		AddressToValidatorIndexIndex: g.indexes[4][:],
+	}
 
-	return bigKeccak.Bytes()
+	return g
 }
 
 // getStorageIndexes is a helper function for getting the correct indexes
 // of the storage slots which need to be modified during bootstrap.
+// The indexes are overwritten by the next call, so that no memory is allocated for them.
 //
 // It is SC dependant, and based on the SC located at:
 // https://github.com/0xPolygon/staking-contracts/
-func getStorageIndexes(validator validators.Validator, index int) *StorageIndexes {
-	storageIndexes := &StorageIndexes{}
-	address := validator.Addr()
+func (g *genesisIndexer) getStorageIndexes(address types.Address, index uint64) *StorageIndexes {
+	// Index for array types is calculated as keccak(slot) + index
+	g.indexes[0] = addOffset(g.validatorsBase, index)
 
 	// Get the indexes for the mappings
 	// The index for the mapping is retrieved with:
 	// keccak(address . slot)
 	// . stands for concatenation (basically appending the bytes)
-	storageIndexes.AddressToIsValidatorIndex = getAddressMapping(
-		address,
-		addressToIsValidatorSlot,
-	)
-
-	storageIndexes.AddressToStakedAmountIndex = getAddressMapping(
-		address,
-		addressToStakedAmountSlot,
-	)
+	g.indexes[1] = g.hasher.addressMapping(address, g.blsPublicKeySlot)
+	g.indexes[2] = g.hasher.addressMapping(address, g.isValidatorSlot)
+	g.indexes[3] = g.hasher.addressMapping(address, g.stakedAmountSlot)
+	g.indexes[4] = g.hasher.addressMapping(address, g.validatorIndexSlot)
 
-	storageIndexes.AddressToValidatorIndexIndex = getAddressMapping(
-		address,
-		addressToValidatorIndexSlot,
-	)
-
-	storageIndexes.ValidatorBLSPublicKeyIndex = getAddressMapping(
-		address,
-		addressToBLSPublicKeySlot,
-	)
-
-	// Index for array types is calculated as keccak(slot) + index
-	// The slot for the dynamic arrays that's put in the keccak needs to be in hex form (padded 64 chars)
-	storageIndexes.ValidatorsIndex = getIndexWithOffset(
-		keccak.Keccak256(nil, common.PadLeftOrTrim(big.NewInt(validatorsSlot).Bytes(), 32)),
-		uint64(index),
-	)
-
-	return storageIndexes
+	return &g.storageIndexes
 }
 
-// setBytesToStorage sets bytes data into storage map from specified base index
-func setBytesToStorage(
-	storageMap map[types.Hash]types.Hash,
-	baseIndexBytes []byte,
-	data []byte,
-) {
-	dataLen := len(data)
-	baseIndex := types.BytesToHash(baseIndexBytes)
-
-	if dataLen <= 31 {
-		bytes := types.Hash{}
-
-		copy(bytes[:len(data)], data)
+// PredeployParams contains the values used to predeploy the PoS staking contract
+type PredeployParams struct {
+	MinValidatorCount uint64
+	MaxValidatorCount uint64
+	EpochSize         uint64 // Blocks per epoch for the epoch aware contract, 0 to disable epochs
+	Bytecode          []byte // Code of the staking contract, defaults to StakingSCBytecode
+	BytecodeHash      string // Hex keccak256 hash the code is pinned to, required by the extensions
 
-		// Set 2*Size at the first byte
-		bytes[len(bytes)-1] = byte(dataLen * 2)
+	// ERC-20 token staked in place of the native coin, the zero address for native staking
+	StakingToken types.Address
 
-		storageMap[baseIndex] = bytes
+	MissedBlocksThreshold uint64 // Consecutive missed blocks that jail a validator, 0 to disable jailing
+	JailCooldown          uint64 // Blocks a jailed validator waits before it can unjail
 
-		return
-	}
+	Admin          types.Address // Admin of the governed contract, the zero address for fixed parameters
+	StakeThreshold *big.Int      // Minimum validator stake of the governed contract, defaults to DefaultStakedBalance
 
-	// Set size at the base index
-	baseSlot := types.Hash{}
-	baseSlot[31] = byte(2*dataLen + 1)
-	storageMap[baseIndex] = baseSlot
+	// Stakes of the genesis validators, the ones not in it are staked with DefaultStakedBalance
+	Stakes map[types.Address]*big.Int
 
-	zeroIndex := keccak.Keccak256(nil, baseIndexBytes)
-	numBytesInSlot := 256 / 8
+	// Metadata of the genesis validators
+	Metadata map[types.Address]*ValidatorMetadata
 
-	for i := 0; i < dataLen; i++ {
-		offset := i / numBytesInSlot
+	// Vesting schedules locking the stake of the genesis validators
+	LockSchedules map[types.Address]*LockSchedule
 
-		slotIndex := types.BytesToHash(getIndexWithOffset(zeroIndex, uint64(offset)))
-		byteIndex := i % numBytesInSlot
+	DelegatorRewards bool // Set up the reward pools of the delegator rewards contract
 
-		slot := storageMap[slotIndex]
-		slot[byteIndex] = data[i]
+	// Addresses placed in the candidate pool of the ranked admission contract, staked but not active
+	Candidates []GenesisCandidate
 
-		storageMap[slotIndex] = slot
-	}
+	// Genesis stakers whose rewards are added to their stake, for the auto-compounding contract
+	AutoCompound map[types.Address]bool
 }
 
-// PredeployParams contains the values used to predeploy the PoS staking contract
-type PredeployParams struct {
-	MinValidatorCount uint64
-	MaxValidatorCount uint64
+// usesExtensions returns true if the params set any storage slots
+// that are not part of the contract in StakingSCBytecode
+func (p *PredeployParams) usesExtensions() bool {
+	return p.EpochSize > 0 ||
+		len(p.Metadata) > 0 ||
+		len(p.LockSchedules) > 0 ||
+		p.DelegatorRewards ||
+		len(p.Candidates) > 0 ||
+		len(p.AutoCompound) > 0 ||
+		p.StakingToken != types.ZeroAddress ||
+		p.MissedBlocksThreshold > 0 ||
+		p.Admin != types.ZeroAddress
 }
 
 // StorageIndexes is a wrapper for different storage indexes that
@@ -144,7 +164,27 @@ type StorageIndexes struct {
 	AddressToValidatorIndexIndex []byte // mapping(address => uint256)
 }
 
-// Slot definitions for SC storage
+// Slot definitions for SC storage.
+//
+// Slots 0 to 7 are the layout of the contract in StakingSCBytecode, kept by every version.
+// The extensions append their slots after them, each in its own range of one slot space
+// shared by all the versions, so that an extended contract can combine them:
+//
+//	8-10   epoch transitions (epoch.go)
+//	11     validator metadata (metadata.go)
+//	12     ERC-20 staking token (erc20.go)
+//	13-15  jailing (jail.go)
+//	16-17  governance (governance.go)
+//	18     vesting lock schedules (vesting.go)
+//	19-20  delegator rewards (rewards.go)
+//	21-23  ranked admission (admission.go)
+//	24-25  auto-compounding (compound.go)
+//
+// A contract leaves the slots of the extensions it doesn't implement unused.
+//
+// This package ships no contract implementing the extensions. Their slots, methods and events
+// describe the interface an extended contract has to implement, and its code is passed in
+// PredeployParams.Bytecode, pinned to PredeployParams.BytecodeHash
 var (
 	validatorsSlot              = int64(0) // Slot 0
 	addressToIsValidatorSlot    = int64(1) // Slot 1
@@ -163,18 +203,65 @@ const (
 )
 
 // PredeployStakingSC is a helper method for setting up the staking smart contract account,
-// using the passed in validators as pre-staked validators
+// using the passed in validators as pre-staked validators.
+// The account is allocated at AddrStakingContract, or at the addresses
+// of PredeployStakingInstances for several contracts
 func PredeployStakingSC(
 	vals validators.Validators,
 	params PredeployParams,
 ) (*chain.GenesisAccount, error) {
 	// Set the code for the staking smart contract
 	// Code retrieved from https://github.com/0xPolygon/staking-contracts
-	scHex, _ := hex.DecodeHex(StakingSCBytecode)
-	stakingAccount := &chain.GenesisAccount{
-		Code: scHex,
+	scHex := params.Bytecode
+
+	if params.usesExtensions() {
+		if err := verifyExtendedCode(scHex, params.BytecodeHash); err != nil {
+			return nil, err
+		}
+	}
+
+	if scHex == nil {
+		code, err := StakingSCCode()
+		if err != nil {
+			return nil, fmt.Errorf("unable to load StakingSCBytecode, %w", err)
+		}
+
+		scHex = code
+	}
+
+	if params.BytecodeHash != "" {
+		if err := VerifyCodeHash(scHex, params.BytecodeHash); err != nil {
+			return nil, err
+		}
 	}
 
+	// Generate the account storage map
+	storageMap := make(StorageMap)
+
+	stakedAmount, err := WriteStakingStorage(storageMap, vals, params)
+	if err != nil {
+		return nil, err
+	}
+
+	return &chain.GenesisAccount{
+		Code:    scHex,
+		Storage: storageMap,
+		// Set the Staking SC balance to numValidators * defaultStakedBalance,
+		// unless the stake is held in the ERC-20 token (see PrefundERC20Stake)
+		Balance: totalStakeBalance(&params, stakedAmount),
+	}, nil
+}
+
+// WriteStakingStorage writes the genesis storage of the staking contract to the writer,
+// returning the total staked amount.
+//
+// The entries are generated one validator at a time, so the memory used doesn't depend
+// on the size of the validator set when the writer doesn't hold them in memory
+func WriteStakingStorage(
+	w StorageWriter,
+	vals validators.Validators,
+	params PredeployParams,
+) (*big.Int, error) {
 	// Parse the default staked balance value into *big.Int
 	val := DefaultStakedBalance
 	bigDefaultStakedBalance, err := types.ParseUint256orHex(&val)
@@ -183,75 +270,218 @@ func PredeployStakingSC(
 		return nil, fmt.Errorf("unable to generate DefaultStatkedBalance, %w", err)
 	}
 
-	// Generate the empty account storage map
-	storageMap := make(map[types.Hash]types.Hash)
-	bigTrueValue := big.NewInt(1)
+	// The threshold is only stored, and enforced, by the governed contract
+	if params.StakeThreshold != nil && params.Admin == types.ZeroAddress {
+		return nil, fmt.Errorf("stake threshold %w", ErrAdminRequired)
+	}
+
+	stakeThreshold := bigDefaultStakedBalance
+	if params.StakeThreshold != nil {
+		stakeThreshold = params.StakeThreshold
+	}
+
+	// Genesis validators are staked with the default staked balance, unless their stake is set
+	if len(params.Stakes) == 0 && stakeThreshold.Cmp(bigDefaultStakedBalance) > 0 {
+		return nil, ErrThresholdAboveStake
+	}
+
+	stakeOf := func(address types.Address) *big.Int {
+		if stake, ok := params.Stakes[address]; ok {
+			return stake
+		}
+
+		return bigDefaultStakedBalance
+	}
+
+	// Looked up for every entry of the genesis params, which can be as many as the validators
+	genesisVals := validatorAddressSet(vals)
+
+	if err := validateGenesisStakes(params.Stakes, vals, genesisVals, stakeOf, stakeThreshold); err != nil {
+		return nil, err
+	}
+
+	if err := validateGenesisMetadata(params.Metadata, genesisVals); err != nil {
+		return nil, err
+	}
+
+	if err := validateGenesisLockSchedules(params.LockSchedules, genesisVals, stakeOf); err != nil {
+		return nil, err
+	}
+
+	if err := validateGenesisCandidates(
+		params.Candidates,
+		vals,
+		genesisVals,
+		params.MaxValidatorCount,
+		stakeOf,
+	); err != nil {
+		return nil, err
+	}
+
+	if err := validateGenesisAutoCompound(params.AutoCompound, genesisVals, params.Candidates); err != nil {
+		return nil, err
+	}
+
+	// The values are the same for every validator, apart from its index and stake
+	trueValue := types.BytesToHash(big.NewInt(1).Bytes())
 	stakedAmount := big.NewInt(0)
-	bigMinNumValidators := big.NewInt(int64(params.MinValidatorCount))
-	bigMaxNumValidators := big.NewInt(int64(params.MaxValidatorCount))
-	valsLen := big.NewInt(0)
+	valsLen := uint64(0)
+	indexer := newGenesisIndexer()
 
 	if vals != nil {
-		valsLen = big.NewInt(int64(vals.Len()))
-
-		for idx := 0; idx < vals.Len(); idx++ {
-			validator := vals.At(uint64(idx))
+		valsLen = uint64(vals.Len())
 
-			// Update the total staked amount
-			stakedAmount = stakedAmount.Add(stakedAmount, bigDefaultStakedBalance)
+		for idx := uint64(0); idx < valsLen; idx++ {
+			validator := vals.At(idx)
+			address := validator.Addr()
+			stake := stakeOf(address)
 
 			// Get the storage indexes
-			storageIndexes := getStorageIndexes(validator, idx)
+			storageIndexes := indexer.getStorageIndexes(address, idx)
 
 			// Set the value for the validators array
-			storageMap[types.BytesToHash(storageIndexes.ValidatorsIndex)] =
-				types.BytesToHash(
-					validator.Addr().Bytes(),
-				)
+			w.SetStorage(
+				types.BytesToHash(storageIndexes.ValidatorsIndex),
+				types.BytesToHash(address.Bytes()),
+			)
 
 			if blsValidator, ok := validator.(*validators.BLSValidator); ok {
-				setBytesToStorage(
-					storageMap,
-					storageIndexes.ValidatorBLSPublicKeyIndex,
+				indexer.hasher.setBytesToStorage(
+					w,
+					types.BytesToHash(storageIndexes.ValidatorBLSPublicKeyIndex),
 					blsValidator.BLSPublicKey,
 				)
 			}
 
+			if metadata, ok := params.Metadata[address]; ok {
+				setMetadataStorage(w, indexer.hasher, address, metadata)
+			}
+
+			if schedule, ok := params.LockSchedules[address]; ok {
+				setLockScheduleStorage(w, indexer.hasher, address, schedule)
+			}
+
+			if params.DelegatorRewards {
+				setRewardsStorage(w, indexer.hasher, address, stake)
+			}
+
 			// Set the value for the address -> validator array index mapping
-			storageMap[types.BytesToHash(storageIndexes.AddressToIsValidatorIndex)] =
-				types.BytesToHash(bigTrueValue.Bytes())
+			w.SetStorage(types.BytesToHash(storageIndexes.AddressToIsValidatorIndex), trueValue)
 
 			// Set the value for the address -> staked amount mapping
-			storageMap[types.BytesToHash(storageIndexes.AddressToStakedAmountIndex)] =
-				types.StringToHash(hex.EncodeBig(bigDefaultStakedBalance))
+			w.SetStorage(types.BytesToHash(storageIndexes.AddressToStakedAmountIndex), types.BytesToHash(stake.Bytes()))
+
+			stakedAmount.Add(stakedAmount, stake)
 
 			// Set the value for the address -> validator index mapping
-			storageMap[types.BytesToHash(storageIndexes.AddressToValidatorIndexIndex)] =
-				types.StringToHash(hex.EncodeUint64(uint64(idx)))
+			w.SetStorage(
+				types.BytesToHash(storageIndexes.AddressToValidatorIndexIndex),
+				addOffset(types.ZeroHash, idx),
+			)
 		}
 	}
 
+	if len(params.Candidates) > 0 {
+		stakedAmount.Add(stakedAmount, setCandidatesStorage(w, indexer.hasher, params.Candidates))
+	}
+
+	if len(params.AutoCompound) > 0 {
+		setAutoCompoundStorage(w, indexer.hasher, params.AutoCompound)
+	}
+
 	// Set the value for the total staked amount
-	storageMap[types.BytesToHash(big.NewInt(stakedAmountSlot).Bytes())] =
-		types.BytesToHash(stakedAmount.Bytes())
+	w.SetStorage(getSlotHash(stakedAmountSlot), types.BytesToHash(stakedAmount.Bytes()))
 
 	// Set the value for the size of the validators array
-	storageMap[types.BytesToHash(big.NewInt(validatorsSlot).Bytes())] =
-		types.BytesToHash(valsLen.Bytes())
+	w.SetStorage(getSlotHash(validatorsSlot), addOffset(types.ZeroHash, valsLen))
 
 	// Set the value for the minimum number of validators
-	storageMap[types.BytesToHash(big.NewInt(minNumValidatorSlot).Bytes())] =
-		types.BytesToHash(bigMinNumValidators.Bytes())
+	w.SetStorage(getSlotHash(minNumValidatorSlot), addOffset(types.ZeroHash, params.MinValidatorCount))
 
 	// Set the value for the maximum number of validators
-	storageMap[types.BytesToHash(big.NewInt(maxNumValidatorSlot).Bytes())] =
-		types.BytesToHash(bigMaxNumValidators.Bytes())
+	w.SetStorage(getSlotHash(maxNumValidatorSlot), addOffset(types.ZeroHash, params.MaxValidatorCount))
+
+	if params.EpochSize > 0 {
+		setEpochStorage(w, params.EpochSize)
+	}
+
+	if params.StakingToken != types.ZeroAddress {
+		setStakingTokenStorage(w, params.StakingToken)
+	}
+
+	if params.MissedBlocksThreshold > 0 {
+		setJailStorage(w, params.MissedBlocksThreshold, params.JailCooldown)
+	}
+
+	if params.Admin != types.ZeroAddress {
+		setGovernanceStorage(w, params.Admin, stakeThreshold)
+	}
+
+	return stakedAmount, nil
+}
+
+// validateGenesisStakes checks that the stakes are set for genesis validators only,
+// and that every genesis validator is staked at or above the threshold
+func validateGenesisStakes(
+	stakes map[types.Address]*big.Int,
+	vals validators.Validators,
+	genesisVals map[types.Address]bool,
+	stakeOf func(types.Address) *big.Int,
+	threshold *big.Int,
+) error {
+	if len(stakes) == 0 {
+		return nil
+	}
+
+	for addr, stake := range stakes {
+		if !genesisVals[addr] {
+			return fmt.Errorf("%w: stake for %s, which is not a genesis validator", ErrInvalidGenesisStake, addr)
+		}
 
-	// Save the storage map
-	stakingAccount.Storage = storageMap
+		if stake == nil || stake.Sign() <= 0 {
+			return fmt.Errorf("%w: stake for %s is not positive", ErrInvalidGenesisStake, addr)
+		}
+	}
 
-	// Set the Staking SC balance to numValidators * defaultStakedBalance
-	stakingAccount.Balance = stakedAmount
+	for idx := 0; idx < vals.Len(); idx++ {
+		addr := vals.At(uint64(idx)).Addr()
+
+		if stakeOf(addr).Cmp(threshold) < 0 {
+			return fmt.Errorf("%w: %s", ErrThresholdAboveStake, addr)
+		}
+	}
+
+	return nil
+}
+
+// validatorAddressSet returns the set of the validator addresses
+func validatorAddressSet(vals validators.Validators) map[types.Address]bool {
+	if vals == nil {
+		return map[types.Address]bool{}
+	}
+
+	set := make(map[types.Address]bool, vals.Len())
+	for idx := 0; idx < vals.Len(); idx++ {
+		set[vals.At(uint64(idx)).Addr()] = true
+	}
+
+	return set
+}
+
+// verifyExtendedCode checks that the code of a contract using extensions is pinned by its hash,
+// and is not the base contract in StakingSCBytecode
+func verifyExtendedCode(code []byte, codeHash string) error {
+	if code == nil {
+		return ErrExtensionBytecodeRequired
+	}
+
+	if codeHash == "" {
+		return ErrExtensionBytecodeHashRequired
+	}
+
+	if VerifyCodeHash(code, StakingSCCodeHash) == nil {
+		return ErrBaseContractExtensions
+	}
 
-	return stakingAccount, nil
+	return nil
 }
@@ -0,0 +1,258 @@
+package staking
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+	"testing"
+
+	"github.com/0xPolygon/polygon-edge/helper/common"
+	"github.com/0xPolygon/polygon-edge/helper/keccak"
+	"github.com/0xPolygon/polygon-edge/types"
+	"github.com/0xPolygon/polygon-edge/validators"
+)
+
+// testExtendedBytecode stands in for the code of an extended staking contract,
+// which the package doesn't ship, with the hash it is pinned to
+var (
+	testExtendedBytecode     = []byte{0x60, 0x80}
+	testExtendedBytecodeHash = "0x1a578b7a4b0b5755db6d121b4118d4bc68fe170dca840c59bc922f14175a76b0"
+)
+
+// testBLSValidators returns a BLS validator set with n validators, whose addresses
+// and public keys are derived from their index
+func testBLSValidators(n int) validators.Validators {
+	set := make([]*validators.BLSValidator, 0, n)
+
+	for idx := 0; idx < n; idx++ {
+		seed := make([]byte, 8)
+		binary.BigEndian.PutUint64(seed, uint64(idx)+1)
+
+		key := make([]byte, 0, 48)
+		for len(key) < 48 {
+			key = append(key, keccak.Keccak256(nil, append(seed, byte(len(key))))...)
+		}
+
+		set = append(set, validators.NewBLSValidator(types.BytesToAddress(seed), key[:48]))
+	}
+
+	return validators.NewBLSValidatorSet(set...)
+}
+
+// baselineIndexWithOffset adds the offset to the index the way the storage was
+// generated before the slot hasher, through big.Int
+func baselineIndexWithOffset(keccakHash []byte, offset uint64) []byte {
+	bigKeccak := new(big.Int).SetBytes(keccakHash)
+	bigKeccak.Add(bigKeccak, new(big.Int).SetUint64(offset))
+
+	return bigKeccak.Bytes()
+}
+
+// baselineBytesToStorage writes the bytes value from the base index the way
+// the storage was generated before the slot hasher
+func baselineBytesToStorage(storageMap map[types.Hash]types.Hash, baseIndexBytes []byte, data []byte) {
+	dataLen := len(data)
+	baseIndex := types.BytesToHash(baseIndexBytes)
+
+	if dataLen <= 31 {
+		bytes := types.Hash{}
+
+		copy(bytes[:len(data)], data)
+
+		// Set 2*Size at the last byte
+		bytes[len(bytes)-1] = byte(dataLen * 2)
+
+		storageMap[baseIndex] = bytes
+
+		return
+	}
+
+	// Set 2*Size+1 at the base index, as a full uint256
+	bigLength := new(big.Int).SetUint64(uint64(dataLen))
+	bigLength.Lsh(bigLength, 1)
+	bigLength.Add(bigLength, big.NewInt(1))
+
+	storageMap[baseIndex] = types.BytesToHash(bigLength.Bytes())
+
+	// Set the data in 32 byte chunks from keccak(baseIndex),
+	// with the last chunk padded with zeros on the right
+	zeroIndex := keccak.Keccak256(nil, baseIndex.Bytes())
+
+	for offset := 0; offset*types.HashLength < dataLen; offset++ {
+		chunk := types.Hash{}
+
+		copy(chunk[:], data[offset*types.HashLength:])
+
+		storageMap[types.BytesToHash(baselineIndexWithOffset(zeroIndex, uint64(offset)))] = chunk
+	}
+}
+
+// baselineStakingStorage builds the genesis storage of the base contract the way
+// PredeployStakingSC did before the storage was streamed, hashing every index from scratch
+func baselineStakingStorage(vals validators.Validators, params PredeployParams) map[types.Hash]types.Hash {
+	val := DefaultStakedBalance
+	bigDefaultStakedBalance, _ := types.ParseUint256orHex(&val)
+
+	storageMap := make(map[types.Hash]types.Hash)
+	stakedAmount := big.NewInt(0)
+	valsLen := big.NewInt(int64(vals.Len()))
+
+	for idx := 0; idx < vals.Len(); idx++ {
+		validator := vals.At(uint64(idx))
+		address := validator.Addr()
+
+		stakedAmount.Add(stakedAmount, bigDefaultStakedBalance)
+
+		validatorsIndex := new(big.Int).SetBytes(
+			keccak.Keccak256(nil, common.PadLeftOrTrim(big.NewInt(validatorsSlot).Bytes(), 32)),
+		)
+		validatorsIndex.Add(validatorsIndex, big.NewInt(int64(idx)))
+
+		storageMap[types.BytesToHash(validatorsIndex.Bytes())] = types.BytesToHash(address.Bytes())
+
+		if blsValidator, ok := validator.(*validators.BLSValidator); ok {
+			baselineBytesToStorage(
+				storageMap,
+				getAddressMapping(address, addressToBLSPublicKeySlot),
+				blsValidator.BLSPublicKey,
+			)
+		}
+
+		storageMap[types.BytesToHash(getAddressMapping(address, addressToIsValidatorSlot))] =
+			types.BytesToHash(big.NewInt(1).Bytes())
+		storageMap[types.BytesToHash(getAddressMapping(address, addressToStakedAmountSlot))] =
+			types.BytesToHash(bigDefaultStakedBalance.Bytes())
+		storageMap[types.BytesToHash(getAddressMapping(address, addressToValidatorIndexSlot))] =
+			types.BytesToHash(big.NewInt(int64(idx)).Bytes())
+	}
+
+	storageMap[types.BytesToHash(big.NewInt(stakedAmountSlot).Bytes())] = types.BytesToHash(stakedAmount.Bytes())
+	storageMap[types.BytesToHash(big.NewInt(validatorsSlot).Bytes())] = types.BytesToHash(valsLen.Bytes())
+	storageMap[types.BytesToHash(big.NewInt(minNumValidatorSlot).Bytes())] =
+		types.BytesToHash(new(big.Int).SetUint64(params.MinValidatorCount).Bytes())
+	storageMap[types.BytesToHash(big.NewInt(maxNumValidatorSlot).Bytes())] =
+		types.BytesToHash(new(big.Int).SetUint64(params.MaxValidatorCount).Bytes())
+
+	return storageMap
+}
+
+func TestPredeployStakingSCMatchesBaseline(t *testing.T) {
+	t.Parallel()
+
+	for _, n := range []int{0, 1, 3, 300} {
+		n := n
+
+		t.Run(fmt.Sprintf("%d validators", n), func(t *testing.T) {
+			t.Parallel()
+
+			vals := testBLSValidators(n)
+			params := PredeployParams{
+				MinValidatorCount: 1,
+				MaxValidatorCount: 1000,
+			}
+
+			account, err := PredeployStakingSC(vals, params)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			expected := baselineStakingStorage(vals, params)
+
+			if len(account.Storage) != len(expected) {
+				t.Fatalf("%d slots written, expected %d", len(account.Storage), len(expected))
+			}
+
+			for slot, value := range expected {
+				if account.Storage[slot] != value {
+					t.Fatalf("slot %s is %s, expected %s", slot, account.Storage[slot], value)
+				}
+			}
+		})
+	}
+}
+
+func TestPredeployStakingSCExtendedCode(t *testing.T) {
+	t.Parallel()
+
+	baseCode, err := StakingSCCode()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testTable := []struct {
+		name     string
+		code     []byte
+		codeHash string
+		err      error
+	}{
+		{"no code", nil, "", ErrExtensionBytecodeRequired},
+		{"code without hash", testExtendedBytecode, "", ErrExtensionBytecodeHashRequired},
+		{"code with another hash", []byte{0x60, 0x81}, testExtendedBytecodeHash, ErrCodeHashMismatch},
+		{"base contract", baseCode, StakingSCCodeHash, ErrBaseContractExtensions},
+		{"pinned code", testExtendedBytecode, testExtendedBytecodeHash, nil},
+	}
+
+	for _, test := range testTable {
+		test := test
+
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := PredeployStakingSC(testBLSValidators(1), PredeployParams{
+				MinValidatorCount: 1,
+				MaxValidatorCount: 1,
+				EpochSize:         10,
+				Bytecode:          test.code,
+				BytecodeHash:      test.codeHash,
+			})
+
+			if !errors.Is(err, test.err) {
+				t.Fatalf("expected %v, got %v", test.err, err)
+			}
+		})
+	}
+}
+
+// discardStorageWriter drops the storage entries, like a writer streaming them out would
+type discardStorageWriter struct{}
+
+func (discardStorageWriter) SetStorage(types.Hash, types.Hash) {}
+
+func BenchmarkPredeployStakingSC(b *testing.B) {
+	for _, n := range []int{1000, 10000, 100000} {
+		vals := testBLSValidators(n)
+		params := PredeployParams{
+			MinValidatorCount: 1,
+			MaxValidatorCount: uint64(n),
+		}
+
+		b.Run(fmt.Sprintf("baseline %d validators", n), func(b *testing.B) {
+			b.ReportAllocs()
+
+			for i := 0; i < b.N; i++ {
+				baselineStakingStorage(vals, params)
+			}
+		})
+
+		b.Run(fmt.Sprintf("%d validators", n), func(b *testing.B) {
+			b.ReportAllocs()
+
+			for i := 0; i < b.N; i++ {
+				if _, err := PredeployStakingSC(vals, params); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+
+		b.Run(fmt.Sprintf("streaming %d validators", n), func(b *testing.B) {
+			b.ReportAllocs()
+
+			for i := 0; i < b.N; i++ {
+				if _, err := WriteStakingStorage(discardStorageWriter{}, vals, params); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
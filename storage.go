@@ -0,0 +1,261 @@
+package staking
+
+import (
+	"errors"
+	"math/big"
+
+	"github.com/0xPolygon/polygon-edge/helper/common"
+	"github.com/0xPolygon/polygon-edge/helper/keccak"
+	"github.com/0xPolygon/polygon-edge/types"
+)
+
+// maxArrayLength is the upper bound for dynamic arrays decoded from storage,
+// guarding against allocating memory for a corrupted length slot
+const maxArrayLength = 1 << 20
+
+// maxBytesLength is the upper bound for bytes values decoded from storage
+const maxBytesLength = 1 << 24
+
+var (
+	ErrInvalidArrayLength   = errors.New("invalid storage array length")
+	ErrInvalidBytesEncoding = errors.New("invalid storage bytes encoding")
+)
+
+// StorageReader provides read access to the storage of the staking contract,
+// either from the genesis account or from the live state
+type StorageReader interface {
+	GetStorage(key types.Hash) types.Hash
+}
+
+// StorageWriter receives the storage entries of the staking contract as they are generated,
+// so that large genesis states don't have to be built in memory first
+type StorageWriter interface {
+	SetStorage(key, value types.Hash)
+}
+
+// StorageMap is a StorageReader and StorageWriter over an in-memory storage map,
+// such as the one of the genesis account
+type StorageMap map[types.Hash]types.Hash
+
+// GetStorage returns the value at the given key, or the zero hash if it is not set
+func (m StorageMap) GetStorage(key types.Hash) types.Hash {
+	return m[key]
+}
+
+// SetStorage sets the value at the given key
+func (m StorageMap) SetStorage(key, value types.Hash) {
+	m[key] = value
+}
+
+// addOffset adds the offset to the storage index,
+// wrapping around at 2^256 like the EVM does
+func addOffset(index types.Hash, offset uint64) types.Hash {
+	for i := types.HashLength - 1; i >= 0 && offset > 0; i-- {
+		sum := uint64(index[i]) + offset&0xff
+		index[i] = byte(sum)
+		offset = offset>>8 + sum>>8
+	}
+
+	return index
+}
+
+// slotHasher computes mapping indexes reusing the same keccak state and buffer,
+// avoiding allocations when many indexes are needed
+type slotHasher struct {
+	hash *keccak.Keccak
+	buf  [2 * types.HashLength]byte
+	slot [types.HashLength]byte
+}
+
+// newSlotHasher creates a new slot hasher
+func newSlotHasher() *slotHasher {
+	return &slotHasher{
+		hash: keccak.NewKeccak256(),
+	}
+}
+
+// addressMapping returns the index of the address in the mapping at the given slot,
+// the same as getAddressMapping does
+func (h *slotHasher) addressMapping(address types.Address, slot types.Hash) (index types.Hash) {
+	copy(h.buf[types.HashLength-types.AddressLength:types.HashLength], address.Bytes())
+	copy(h.buf[types.HashLength:], slot.Bytes())
+
+	h.hash.Reset()
+	h.hash.Write(h.buf[:]) //nolint:errcheck
+	h.hash.Sum(index[:0])
+
+	return index
+}
+
+// dataIndex returns keccak(slot), the index the elements of the dynamic array
+// or the data of the long bytes value at the slot start from
+func (h *slotHasher) dataIndex(slot types.Hash) (index types.Hash) {
+	h.slot = slot
+
+	h.hash.Reset()
+	h.hash.Write(h.slot[:]) //nolint:errcheck
+	h.hash.Sum(index[:0])
+
+	return index
+}
+
+// setBytesToStorage writes bytes data into storage from specified base index,
+// following the Solidity encoding of bytes and string values
+//
+// More information:
+// https://docs.soliditylang.org/en/latest/internals/layout_in_storage.html#bytes-and-string
+func (h *slotHasher) setBytesToStorage(
+	w StorageWriter,
+	baseIndex types.Hash,
+	data []byte,
+) {
+	dataLen := len(data)
+
+	if dataLen <= 31 {
+		bytes := types.Hash{}
+
+		copy(bytes[:len(data)], data)
+
+		// Set 2*Size at the last byte
+		bytes[len(bytes)-1] = byte(dataLen * 2)
+
+		w.SetStorage(baseIndex, bytes)
+
+		return
+	}
+
+	// Set 2*Size+1 at the base index, as a full uint256
+	w.SetStorage(baseIndex, addOffset(types.ZeroHash, 2*uint64(dataLen)+1))
+
+	// Set the data in 32 byte chunks from keccak(baseIndex),
+	// with the last chunk padded with zeros on the right
+	zeroIndex := h.dataIndex(baseIndex)
+
+	for offset := 0; offset*types.HashLength < dataLen; offset++ {
+		chunk := types.Hash{}
+
+		copy(chunk[:], data[offset*types.HashLength:])
+
+		w.SetStorage(addOffset(zeroIndex, uint64(offset)), chunk)
+	}
+}
+
+// getSlotHash returns the storage key of a fixed slot
+func getSlotHash(slot int64) types.Hash {
+	return types.BytesToHash(big.NewInt(slot).Bytes())
+}
+
+// getArrayElementIndex returns the storage index of the element at the given
+// index of the dynamic array located at the given slot
+func getArrayElementIndex(slot int64, index uint64) types.Hash {
+	return addOffset(types.BytesToHash(keccak.Keccak256(nil, getSlotHash(slot).Bytes())), index)
+}
+
+// readBig reads the value at the given storage key as an uint256
+func readBig(r StorageReader, key types.Hash) *big.Int {
+	value := r.GetStorage(key)
+
+	return new(big.Int).SetBytes(value.Bytes())
+}
+
+// readUint64 reads the value at the given storage key as an uint64,
+// returning false if the value doesn't fit
+func readUint64(r StorageReader, key types.Hash) (uint64, bool) {
+	value := readBig(r, key)
+	if !value.IsUint64() {
+		return 0, false
+	}
+
+	return value.Uint64(), true
+}
+
+// readAddress reads the address stored in the lower 20 bytes of the given storage key
+func readAddress(r StorageReader, key types.Hash) types.Address {
+	value := r.GetStorage(key)
+
+	return types.BytesToAddress(value.Bytes())
+}
+
+// readValidators reads the validators array from the staking contract storage
+func readValidators(r StorageReader) ([]types.Address, error) {
+	return readAddressArray(r, validatorsSlot)
+}
+
+// readAddressArray reads the address array at the given slot from the staking contract storage
+func readAddressArray(r StorageReader, slot int64) ([]types.Address, error) {
+	length, ok := readUint64(r, getSlotHash(slot))
+	if !ok || length > maxArrayLength {
+		return nil, ErrInvalidArrayLength
+	}
+
+	return readAddresses(r, slot, length), nil
+}
+
+// readAddressArrayLenient reads the address array at the given slot the same as readAddressArray,
+// but reads the first maxArrayLength elements if the length slot is corrupt instead of failing,
+// so that the array can be repaired
+func readAddressArrayLenient(r StorageReader, slot int64) []types.Address {
+	length, ok := readUint64(r, getSlotHash(slot))
+	if !ok || length > maxArrayLength {
+		length = maxArrayLength
+	}
+
+	return readAddresses(r, slot, length)
+}
+
+// readAddresses reads the first length elements of the address array at the given slot
+func readAddresses(r StorageReader, slot int64, length uint64) []types.Address {
+	addresses := make([]types.Address, length)
+	base := getArrayElementIndex(slot, 0)
+
+	for idx := uint64(0); idx < length; idx++ {
+		addresses[idx] = readAddress(r, addOffset(base, idx))
+	}
+
+	return addresses
+}
+
+// getBytesFromStorage reads the bytes value at the given base index,
+// stored as slotHasher.setBytesToStorage does
+func getBytesFromStorage(r StorageReader, baseIndexBytes []byte) ([]byte, error) {
+	baseIndex := types.BytesToHash(baseIndexBytes)
+	baseSlot := r.GetStorage(baseIndex)
+
+	// Short values keep their data and 2*length in the base slot
+	if baseSlot[len(baseSlot)-1]&1 == 0 {
+		length := int(baseSlot[len(baseSlot)-1] / 2)
+		if length > 31 {
+			return nil, ErrInvalidBytesEncoding
+		}
+
+		// The bytes after the data must be empty
+		for _, b := range baseSlot[length : len(baseSlot)-1] {
+			if b != 0 {
+				return nil, ErrInvalidBytesEncoding
+			}
+		}
+
+		return append([]byte{}, baseSlot[:length]...), nil
+	}
+
+	// Long values keep 2*length+1 in the base slot, and their data from keccak(baseIndex)
+	bigLength := new(big.Int).SetBytes(baseSlot.Bytes())
+	bigLength.Rsh(bigLength, 1)
+
+	if !bigLength.IsUint64() || bigLength.Uint64() > maxBytesLength || bigLength.Uint64() <= 31 {
+		return nil, ErrInvalidBytesEncoding
+	}
+
+	length := int(bigLength.Uint64())
+	data := make([]byte, 0, length)
+	zeroIndex := types.BytesToHash(keccak.Keccak256(nil, baseIndex.Bytes()))
+
+	for offset := uint64(0); len(data) < length; offset++ {
+		slot := r.GetStorage(addOffset(zeroIndex, offset))
+		size := common.Min(uint64(length-len(data)), types.HashLength)
+
+		data = append(data, slot[:size]...)
+	}
+
+	return data, nil
+}
@@ -0,0 +1,141 @@
+package staking
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/0xPolygon/polygon-edge/types"
+)
+
+// bytesBaseSlot is the base slot of the bytes values of the storage tests
+var bytesBaseSlot = getSlotHash(5)
+
+// repeatBytes returns the bytes 1, 2, ... up to the length, wrapping around at 255
+func repeatBytes(length int) []byte {
+	data := make([]byte, length)
+	for idx := range data {
+		data[idx] = byte(idx%255 + 1)
+	}
+
+	return data
+}
+
+func FuzzBytesStorageRoundTrip(f *testing.F) {
+	for _, length := range []int{0, 1, 31, 32, 33, 127, 128, 200, 1000} {
+		f.Add(repeatBytes(length))
+	}
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		storage := make(StorageMap)
+		newSlotHasher().setBytesToStorage(storage, bytesBaseSlot, data)
+
+		// Short values take the base slot only, long ones a slot per 32 byte chunk too
+		slots := 1
+		if len(data) > 31 {
+			slots += (len(data) + types.HashLength - 1) / types.HashLength
+		}
+
+		if len(storage) > slots {
+			t.Fatalf("%d bytes written to %d slots, expected at most %d", len(data), len(storage), slots)
+		}
+
+		decoded, err := getBytesFromStorage(storage, bytesBaseSlot.Bytes())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !bytes.Equal(decoded, data) {
+			t.Fatalf("decoded %x, expected %x", decoded, data)
+		}
+	})
+}
+
+func TestBytesStorageLayout(t *testing.T) {
+	t.Parallel()
+
+	// keccak256 of the base slot 5, where the chunks of long values start
+	chunkSlot := types.StringToHash("0x036b6384b5eca791c62761152d0c79bb0604c104a5fb6f4eb0703f3154bb3db0")
+
+	testTable := []struct {
+		name    string
+		length  int
+		storage map[types.Hash]types.Hash
+	}{
+		{
+			name:    "empty value",
+			length:  0,
+			storage: map[types.Hash]types.Hash{bytesBaseSlot: {}},
+		},
+		{
+			name:   "short value keeps twice its length in the last byte",
+			length: 3,
+			storage: map[types.Hash]types.Hash{
+				bytesBaseSlot: types.StringToHash("0x0102030000000000000000000000000000000000000000000000000000000006"),
+			},
+		},
+		{
+			name:   "31 bytes is the longest short value",
+			length: 31,
+			storage: map[types.Hash]types.Hash{
+				bytesBaseSlot: types.StringToHash("0x0102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f3e"),
+			},
+		},
+		{
+			name:   "32 bytes is the shortest long value",
+			length: 32,
+			storage: map[types.Hash]types.Hash{
+				bytesBaseSlot: types.StringToHash("0x41"),
+				chunkSlot:     types.StringToHash("0x0102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f20"),
+			},
+		},
+		{
+			name:   "long value pads its last chunk on the right",
+			length: 40,
+			storage: map[types.Hash]types.Hash{
+				bytesBaseSlot: types.StringToHash("0x51"),
+				chunkSlot:     types.StringToHash("0x0102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f20"),
+				types.StringToHash("0x036b6384b5eca791c62761152d0c79bb0604c104a5fb6f4eb0703f3154bb3db1"): types.StringToHash(
+					"0x2122232425262728000000000000000000000000000000000000000000000000",
+				),
+			},
+		},
+	}
+
+	for _, test := range testTable {
+		test := test
+
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			storage := make(StorageMap)
+			newSlotHasher().setBytesToStorage(storage, bytesBaseSlot, repeatBytes(test.length))
+
+			if len(storage) != len(test.storage) {
+				t.Fatalf("%d slots written, expected %d", len(storage), len(test.storage))
+			}
+
+			for slot, expected := range test.storage {
+				if value, ok := storage[slot]; !ok || value != expected {
+					t.Fatalf("slot %s is %s, expected %s", slot, value, expected)
+				}
+			}
+		})
+	}
+}
+
+func TestBytesStorageInvalidEncoding(t *testing.T) {
+	t.Parallel()
+
+	for name, base := range map[string]types.Hash{
+		"short length over 31":       types.StringToHash("0x40"),
+		"data after the short value": types.StringToHash("0x0000000000000000000000000000000000000000000000000000000000010002"),
+		"long length under 32":       types.StringToHash("0x3f"),
+		"long length too large":      types.StringToHash("0xffffffffffffffffffffffffffffffff"),
+	} {
+		storage := StorageMap{bytesBaseSlot: base}
+
+		if _, err := getBytesFromStorage(storage, bytesBaseSlot.Bytes()); err != ErrInvalidBytesEncoding {
+			t.Fatalf("%s: expected %v, got %v", name, ErrInvalidBytesEncoding, err)
+		}
+	}
+}
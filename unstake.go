@@ -0,0 +1,178 @@
+package staking
+
+import (
+	"errors"
+	"math/big"
+
+	"github.com/0xPolygon/polygon-edge/types"
+	"github.com/umbracle/ethgo"
+	"github.com/umbracle/ethgo/abi"
+)
+
+var (
+	ErrInvalidStakeAmount = errors.New("invalid stake amount")
+	ErrUnknownStakeChange = errors.New("log is not a stake change event")
+
+	stakeMethod          = abi.MustNewMethod("function stake() payable")
+	unstakeMethod        = abi.MustNewMethod("function unstake()")
+	partialUnstakeMethod = abi.MustNewMethod("function unstake(uint256 amount)")
+
+	// Events of the partial unstake contract, emitted in place of Staked and Unstaked
+	stakeIncreasedEvent = abi.MustNewEvent(
+		"event StakeIncreased(address indexed account, uint256 amount, uint256 balance)",
+	)
+	stakeDecreasedEvent = abi.MustNewEvent(
+		"event StakeDecreased(address indexed account, uint256 amount, uint256 balance)",
+	)
+)
+
+// StakeChange is a stake top up or partial unstake emitted by the staking contract
+type StakeChange struct {
+	Account  types.Address
+	Amount   *big.Int // Amount staked or unstaked
+	Balance  *big.Int // Stake of the account after the change
+	Decrease bool     // The amount was unstaked
+}
+
+// Unstaking is the outcome of an unstake in the ranked validator set
+type Unstaking struct {
+	Remaining *big.Int      // Stake left to the staker
+	Removed   bool          // The staker left the validator set
+	Promoted  types.Address // Candidate that took the place of the staker, the zero address if none
+}
+
+// StakeTx builds the transaction staking the amount of native coin, topping up any existing stake
+func (c *Client) StakeTx(from types.Address, nonce uint64, amount *big.Int) (*types.Transaction, error) {
+	if amount == nil || amount.Sign() <= 0 {
+		return nil, ErrInvalidStakeAmount
+	}
+
+	return c.newStakingTx(from, nonce, new(big.Int).Set(amount), stakeMethod)
+}
+
+// UnstakeTx builds the transaction withdrawing the whole stake of the sender
+func (c *Client) UnstakeTx(from types.Address, nonce uint64) (*types.Transaction, error) {
+	return c.newStakingTx(from, nonce, big.NewInt(0), unstakeMethod)
+}
+
+// PartialUnstakeTx builds the transaction withdrawing the amount from the stake of the sender,
+// for the partial unstake contract
+func (c *Client) PartialUnstakeTx(from types.Address, nonce uint64, amount *big.Int) (*types.Transaction, error) {
+	if amount == nil || amount.Sign() <= 0 {
+		return nil, ErrInvalidStakeAmount
+	}
+
+	return c.newStakingTx(from, nonce, big.NewInt(0), partialUnstakeMethod, amount)
+}
+
+// DecodeStakeChange decodes a stake change event emitted by the partial unstake contract,
+// failing for the events of any other contract
+func (c *Client) DecodeStakeChange(log *types.Log) (*StakeChange, error) {
+	ethLog, err := c.contractLog(log)
+	if err != nil {
+		return nil, err
+	}
+
+	return decodeStakeChange(ethLog)
+}
+
+// decodeStakeChange decodes a stake change event, whatever contract emitted it
+func decodeStakeChange(ethLog *ethgo.Log) (*StakeChange, error) {
+	for _, event := range []*abi.Event{stakeIncreasedEvent, stakeDecreasedEvent} {
+		if !event.Match(ethLog) {
+			continue
+		}
+
+		values, err := event.ParseLog(ethLog)
+		if err != nil {
+			return nil, err
+		}
+
+		account, amount, err := decodeStakeEvent(values)
+		if err != nil {
+			return nil, err
+		}
+
+		balance, err := decodeBig(values, "balance")
+		if err != nil {
+			return nil, err
+		}
+
+		return &StakeChange{
+			Account:  account,
+			Amount:   amount,
+			Balance:  balance,
+			Decrease: event == stakeDecreasedEvent,
+		}, nil
+	}
+
+	return nil, ErrUnknownStakeChange
+}
+
+// Unstake removes the whole stake of the address, promoting the highest staked candidate
+// into the place of a leaving validator. It returns the promoted candidate,
+// or the zero address if there is none
+func (s *RankedSet) Unstake(addr types.Address) (types.Address, error) {
+	unstaking, err := s.UnstakeAmount(addr, s.StakeOf(addr))
+	if err != nil {
+		return types.ZeroAddress, err
+	}
+
+	return unstaking.Promoted, nil
+}
+
+// UnstakeAmount removes the amount from the stake of the address as the partial unstake contract does.
+//
+// A validator stays in the set while its remaining stake is at or above the threshold.
+// Otherwise it leaves the set, staying a candidate if it has stake left,
+// and the highest staked candidate meeting the threshold takes its place
+func (s *RankedSet) UnstakeAmount(addr types.Address, amount *big.Int) (*Unstaking, error) {
+	stake := s.StakeOf(addr)
+	if stake.Sign() == 0 {
+		return nil, ErrNotStaked
+	}
+
+	if amount == nil || amount.Sign() <= 0 || amount.Cmp(stake) > 0 {
+		return nil, ErrInvalidStakeAmount
+	}
+
+	remaining := stake.Sub(stake, amount)
+	unstaking := &Unstaking{
+		Remaining: new(big.Int).Set(remaining),
+	}
+
+	index := indexOf(s.Validators, addr)
+
+	if index >= 0 && remaining.Cmp(s.Threshold) < 0 {
+		highest := s.highestCandidate()
+
+		if highest < 0 {
+			if uint64(len(s.Validators)) <= s.MinValidators {
+				return nil, ErrMinValidators
+			}
+
+			s.Validators = removeAddress(s.Validators, addr)
+		} else {
+			// The candidate takes the position of the leaving validator
+			unstaking.Promoted = s.Candidates[highest]
+
+			s.Candidates = removeAddress(s.Candidates, unstaking.Promoted)
+			s.Validators[index] = unstaking.Promoted
+		}
+
+		unstaking.Removed = true
+
+		if remaining.Sign() > 0 {
+			s.Candidates = append(s.Candidates, addr)
+		}
+	}
+
+	if remaining.Sign() == 0 {
+		s.Candidates = removeAddress(s.Candidates, addr)
+		delete(s.Stakes, addr)
+	} else {
+		s.Stakes[addr] = remaining
+	}
+
+	return unstaking, nil
+}
@@ -0,0 +1,178 @@
+package staking
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/0xPolygon/polygon-edge/types"
+	"github.com/umbracle/ethgo"
+	"github.com/umbracle/ethgo/abi"
+)
+
+// Slot definitions for the vesting extension of the SC storage.
+// Locked stake can't be unstaked until it vests
+var (
+	addressToLockScheduleSlot = int64(18) // Slot 18
+)
+
+var (
+	ErrInvalidLockSchedule = errors.New("invalid lock schedule")
+	ErrLockAboveStake      = errors.New("locked amount is above the genesis stake")
+
+	lockScheduleMethod = abi.MustNewMethod(
+		"function lockSchedule(address account) view " +
+			"returns (uint256 start, uint256 cliff, uint256 duration, uint256 amount)",
+	)
+)
+
+// LockSchedule is the linear vesting schedule of an address' stake.
+//
+// Nothing vests until the cliff, after which the amount vests linearly
+// from the start block until it is fully vested at start + duration.
+// It is stored in the mapping(address => LockSchedule) as
+// struct { uint256 start; uint256 cliff; uint256 duration; uint256 amount; }
+type LockSchedule struct {
+	Start    uint64   `json:"start"`    // Block the vesting starts at
+	Cliff    uint64   `json:"cliff"`    // Blocks after the start before anything vests
+	Duration uint64   `json:"duration"` // Blocks after the start until everything is vested
+	Amount   *big.Int `json:"amount"`   // Locked amount at the start
+}
+
+// Validate checks that the schedule is consistent
+func (s *LockSchedule) Validate() error {
+	if s.Amount == nil || s.Amount.Sign() < 0 || s.Duration == 0 || s.Cliff > s.Duration {
+		return ErrInvalidLockSchedule
+	}
+
+	return nil
+}
+
+// Locked returns the amount that is still locked at the given block.
+// The blocks are compared by the time elapsed since the start, so that a schedule
+// ending past the maximum block number doesn't overflow
+func (s *LockSchedule) Locked(blockNumber uint64) *big.Int {
+	if blockNumber < s.Start || blockNumber-s.Start < s.Cliff {
+		return new(big.Int).Set(s.Amount)
+	}
+
+	elapsed := blockNumber - s.Start
+	if elapsed >= s.Duration {
+		return big.NewInt(0)
+	}
+
+	// amount * (start + duration - block) / duration, rounding the locked part up
+	// so that the vested part never exceeds the linear schedule
+	remaining := new(big.Int).SetUint64(s.Duration - elapsed)
+	duration := new(big.Int).SetUint64(s.Duration)
+
+	locked := new(big.Int).Mul(s.Amount, remaining)
+	locked.Add(locked, duration)
+	locked.Sub(locked, big.NewInt(1))
+
+	return locked.Div(locked, duration)
+}
+
+// Unlocked returns the amount that has vested at the given block
+func (s *LockSchedule) Unlocked(blockNumber uint64) *big.Int {
+	return new(big.Int).Sub(s.Amount, s.Locked(blockNumber))
+}
+
+// Withdrawable returns the part of the stake that can be unstaked at the given block
+func (s *LockSchedule) Withdrawable(stake *big.Int, blockNumber uint64) *big.Int {
+	withdrawable := new(big.Int).Sub(stake, s.Locked(blockNumber))
+	if withdrawable.Sign() < 0 {
+		return big.NewInt(0)
+	}
+
+	return withdrawable
+}
+
+// setLockScheduleStorage sets the lock schedule of the address into the genesis storage
+func setLockScheduleStorage(
+	w StorageWriter,
+	h *slotHasher,
+	address types.Address,
+	schedule *LockSchedule,
+) {
+	// The struct fields occupy consecutive slots from the mapping index
+	baseIndex := h.addressMapping(address, getSlotHash(addressToLockScheduleSlot))
+
+	w.SetStorage(baseIndex, addOffset(types.ZeroHash, schedule.Start))
+	w.SetStorage(addOffset(baseIndex, 1), addOffset(types.ZeroHash, schedule.Cliff))
+	w.SetStorage(addOffset(baseIndex, 2), addOffset(types.ZeroHash, schedule.Duration))
+	w.SetStorage(addOffset(baseIndex, 3), types.BytesToHash(schedule.Amount.Bytes()))
+}
+
+// ReadLockSchedule reads the lock schedule of the address from the staking contract storage,
+// returning nil if the address has none
+func ReadLockSchedule(r StorageReader, address types.Address) (*LockSchedule, error) {
+	baseIndex := getAddressMapping(address, addressToLockScheduleSlot)
+
+	fields := make([]*big.Int, 4)
+	for offset := range fields {
+		fields[offset] = readBig(r, addOffset(types.BytesToHash(baseIndex), uint64(offset)))
+	}
+
+	return newLockSchedule(fields[0], fields[1], fields[2], fields[3])
+}
+
+// LockSchedule gets the lock schedule of the address from contract, returning nil if the address has none
+func (c *Client) LockSchedule(from types.Address, address types.Address) (*LockSchedule, error) {
+	outputs, err := c.callView(from, lockScheduleMethod, ethgo.Address(address))
+	if err != nil {
+		return nil, err
+	}
+
+	fields := make([]*big.Int, 4)
+
+	for idx, name := range []string{"start", "cliff", "duration", "amount"} {
+		if fields[idx], err = decodeBig(outputs, name); err != nil {
+			return nil, err
+		}
+	}
+
+	return newLockSchedule(fields[0], fields[1], fields[2], fields[3])
+}
+
+// newLockSchedule builds the lock schedule from its stored fields
+func newLockSchedule(start, cliff, duration, amount *big.Int) (*LockSchedule, error) {
+	if amount.Sign() == 0 {
+		return nil, nil
+	}
+
+	if !start.IsUint64() || !cliff.IsUint64() || !duration.IsUint64() {
+		return nil, ErrInvalidLockSchedule
+	}
+
+	return &LockSchedule{
+		Start:    start.Uint64(),
+		Cliff:    cliff.Uint64(),
+		Duration: duration.Uint64(),
+		Amount:   amount,
+	}, nil
+}
+
+// validateGenesisLockSchedules checks that the genesis lock schedules
+// only lock the stake of genesis validators
+func validateGenesisLockSchedules(
+	schedules map[types.Address]*LockSchedule,
+	genesisVals map[types.Address]bool,
+	stakeOf func(types.Address) *big.Int,
+) error {
+	for addr, schedule := range schedules {
+		if !genesisVals[addr] {
+			return fmt.Errorf("lock schedule for %s, which is not a genesis validator", addr)
+		}
+
+		if err := schedule.Validate(); err != nil {
+			return fmt.Errorf("invalid lock schedule for %s: %w", addr, err)
+		}
+
+		if schedule.Amount.Cmp(stakeOf(addr)) > 0 {
+			return fmt.Errorf("invalid lock schedule for %s: %w", addr, ErrLockAboveStake)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,46 @@
+package staking
+
+import (
+	"math"
+	"math/big"
+	"testing"
+)
+
+func TestLockScheduleLocked(t *testing.T) {
+	t.Parallel()
+
+	testTable := []struct {
+		name     string
+		schedule LockSchedule
+		block    uint64
+		locked   int64
+	}{
+		{"before the start", LockSchedule{Start: 10, Cliff: 5, Duration: 100}, 9, 1000},
+		{"before the cliff", LockSchedule{Start: 10, Cliff: 5, Duration: 100}, 14, 1000},
+		{"at the cliff", LockSchedule{Start: 10, Cliff: 5, Duration: 100}, 15, 950},
+		{"rounding the locked part up", LockSchedule{Start: 0, Duration: 3}, 1, 667},
+		{"at the end", LockSchedule{Start: 10, Cliff: 5, Duration: 100}, 110, 0},
+		{
+			"cliff past the maximum block",
+			LockSchedule{Start: math.MaxUint64 - 10, Cliff: 20, Duration: 40},
+			math.MaxUint64,
+			1000,
+		},
+		{"end past the maximum block", LockSchedule{Start: math.MaxUint64 - 10, Duration: 20}, math.MaxUint64, 500},
+		{"maximum duration", LockSchedule{Start: 1, Duration: math.MaxUint64}, math.MaxUint64, 1},
+	}
+
+	for _, test := range testTable {
+		test := test
+
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			test.schedule.Amount = big.NewInt(1000)
+
+			if locked := test.schedule.Locked(test.block); locked.Cmp(big.NewInt(test.locked)) != 0 {
+				t.Fatalf("%s locked at block %d, expected %d", locked, test.block, test.locked)
+			}
+		})
+	}
+}
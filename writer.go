@@ -0,0 +1,162 @@
+package staking
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"math/big"
+	"sort"
+
+	"github.com/0xPolygon/polygon-edge/chain"
+	"github.com/0xPolygon/polygon-edge/helper/keccak"
+	"github.com/0xPolygon/polygon-edge/state"
+	"github.com/0xPolygon/polygon-edge/types"
+)
+
+// storageEntry is a single storage slot written to a StorageWriter
+type storageEntry struct {
+	key   types.Hash
+	value types.Hash
+}
+
+// JSONStorageWriter collects the storage entries and writes them as a JSON object
+// with the keys in ascending order, the same as the storage of a genesis account
+// is encoded in the genesis file
+type JSONStorageWriter struct {
+	entries []storageEntry
+}
+
+// NewJSONStorageWriter creates a new JSON storage writer
+func NewJSONStorageWriter() *JSONStorageWriter {
+	return &JSONStorageWriter{}
+}
+
+// SetStorage records the value at the given key
+func (j *JSONStorageWriter) SetStorage(key, value types.Hash) {
+	j.entries = append(j.entries, storageEntry{key: key, value: value})
+}
+
+// WriteTo writes the storage entries to the output as a JSON object.
+// If a key was set more than once, the last value is written
+func (j *JSONStorageWriter) WriteTo(out io.Writer) (int64, error) {
+	// The stable sort keeps the writes to the same key in order, the last one wins
+	sort.SliceStable(j.entries, func(a, b int) bool {
+		return bytes.Compare(j.entries[a].key[:], j.entries[b].key[:]) < 0
+	})
+
+	w := &countingWriter{w: bufio.NewWriter(out)}
+
+	w.writeString("{")
+
+	separator := ""
+
+	for idx, entry := range j.entries {
+		if idx+1 < len(j.entries) && j.entries[idx+1].key == entry.key {
+			continue
+		}
+
+		w.writeString(separator)
+		separator = ","
+
+		w.writeString(`"` + entry.key.String() + `":"` + entry.value.String() + `"`)
+	}
+
+	w.writeString("}")
+
+	if w.err == nil {
+		w.err = w.w.Flush()
+	}
+
+	return w.n, w.err
+}
+
+// countingWriter keeps the number of bytes written and the first write error
+type countingWriter struct {
+	w   *bufio.Writer
+	n   int64
+	err error
+}
+
+// writeString writes the string, unless a previous write failed
+func (c *countingWriter) writeString(s string) {
+	if c.err != nil {
+		return
+	}
+
+	n, err := c.w.WriteString(s)
+	c.n += int64(n)
+	c.err = err
+}
+
+// StateSetter sets the storage of accounts in the state, such as *state.Txn does
+type StateSetter interface {
+	SetState(addr types.Address, key, value types.Hash)
+}
+
+// StateWriter writes the storage entries directly into the state of the given account
+type StateWriter struct {
+	state   StateSetter
+	address types.Address
+}
+
+// NewStateWriter creates a new writer into the storage of the account at the given address
+func NewStateWriter(state StateSetter, address types.Address) *StateWriter {
+	return &StateWriter{
+		state:   state,
+		address: address,
+	}
+}
+
+// SetStorage sets the value at the given key of the account storage
+func (s *StateWriter) SetStorage(key, value types.Hash) {
+	s.state.SetState(s.address, key, value)
+}
+
+// SnapshotWriter collects the storage entries as the storage objects of a state object,
+// to be committed into a state snapshot together with the account
+type SnapshotWriter struct {
+	storage []*state.StorageObject
+}
+
+// NewSnapshotWriter creates a new snapshot writer
+func NewSnapshotWriter() *SnapshotWriter {
+	return &SnapshotWriter{}
+}
+
+// SetStorage records the value at the given key, zero values delete the slot
+func (s *SnapshotWriter) SetStorage(key, value types.Hash) {
+	s.storage = append(s.storage, &state.StorageObject{
+		Deleted: value == types.ZeroHash,
+		Key:     key.Bytes(),
+		Val:     value.Bytes(),
+	})
+}
+
+// Commit creates the account at the given address with the collected storage in the snapshot,
+// returning the new snapshot and its state root.
+// The storage of the account is ignored, as it is the one written to the snapshot writer
+func (s *SnapshotWriter) Commit(
+	snap state.Snapshot,
+	address types.Address,
+	account *chain.GenesisAccount,
+) (state.Snapshot, types.Hash) {
+	balance := account.Balance
+	if balance == nil {
+		balance = big.NewInt(0)
+	}
+
+	object := &state.Object{
+		Address:   address,
+		Balance:   balance,
+		Nonce:     account.Nonce,
+		Root:      types.EmptyRootHash,
+		CodeHash:  types.BytesToHash(keccak.Keccak256(nil, account.Code)),
+		DirtyCode: len(account.Code) > 0,
+		Code:      account.Code,
+		Storage:   s.storage,
+	}
+
+	newSnap, root := snap.Commit([]*state.Object{object})
+
+	return newSnap, types.BytesToHash(root)
+}